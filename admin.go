@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// serveAdmin starts the admin HTTP server on addr in the background. It
+// exposes /debug, which reports the state of the collector connection, and
+// /pause and /resume, which pause and resume generating new requests when
+// POSTed to. The request in progress when pausing is completed. /status
+// reports whether the loop is paused.
+func serveAdmin(addr string, conn *connStateTracker, loop *pauseSwitch) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug", func(w http.ResponseWriter, r *http.Request) {
+		state, since := conn.State()
+		fmt.Fprintf(w, "collector connection: %s (since %s)\n", state, since.Format(time.RFC3339))
+	})
+	mux.HandleFunc("/pause", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		loop.pause()
+		log.Print("loop paused")
+		fmt.Fprintln(w, "loop: paused")
+	}))
+	mux.HandleFunc("/resume", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		loop.resume()
+		log.Print("loop resumed")
+		fmt.Fprintln(w, "loop: running")
+	}))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if loop.isPaused() {
+			fmt.Fprintln(w, "loop: paused")
+		} else {
+			fmt.Fprintln(w, "loop: running")
+		}
+	})
+
+	go func() {
+		log.Printf("admin server listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("admin server: %v", err)
+		}
+	}()
+}
+
+// postOnly wraps h, which changes state, to reject any method but POST.
+func postOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// buckets are the ascending edges of coarse ranges that numeric values are
+// reduced to before being used as attribute or label values. Using the raw
+// values would create one time series, or one distinct attribute value, per
+// number seen.
+type buckets []int64
+
+// bucket returns the range of buckets holding v, such as "100-499", or
+// "1000+" for values past the last edge.
+func (b buckets) bucket(v int64) string {
+	lower := int64(0)
+	for _, edge := range b {
+		if v < edge {
+			return fmt.Sprintf("%d-%d", lower, edge-1)
+		}
+		lower = edge
+	}
+	return fmt.Sprintf("%d+", lower)
+}
+
+func (b *buckets) String() string {
+	edges := make([]string, len(*b))
+	for i, edge := range *b {
+		edges[i] = strconv.FormatInt(edge, 10)
+	}
+	return strings.Join(edges, ",")
+}
+
+// Set replaces the edges with the comma separated ones in value.
+func (b *buckets) Set(value string) error {
+	var edges buckets
+	for _, s := range strings.Split(value, ",") {
+		edge, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return err
+		}
+		if edge <= 0 || (len(edges) > 0 && edge <= edges[len(edges)-1]) {
+			return fmt.Errorf("bucket edges must be positive and ascending: %s", value)
+		}
+		edges = append(edges, edge)
+	}
+	*b = edges
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runChain records a trace across a chain of two in-process HTTP services:
+// a client calls service A, which calls service B. The trace context
+// crosses each hop in the request headers using the global propagator, as
+// it would between separate processes. Each service has a tracer of its
+// own, so the spans can be told apart by their instrumentation name.
+func runChain(ctx context.Context) error {
+	serviceB := httptest.NewServer(tracedHandler(otel.Tracer("service-b"), "ServiceB", func(context.Context) error {
+		return nil
+	}))
+	defer serviceB.Close()
+
+	tracerA := otel.Tracer("service-a")
+	serviceA := httptest.NewServer(tracedHandler(tracerA, "ServiceA", func(ctx context.Context) error {
+		return tracedGet(ctx, tracerA, serviceB.URL)
+	}))
+	defer serviceA.Close()
+
+	ctx, span := otel.Tracer("client").Start(ctx, "Chain")
+	defer span.End()
+	if err := tracedGet(ctx, otel.Tracer("client"), serviceA.URL); err != nil {
+		return err
+	}
+	log.Printf("chain trace_id=%s", span.SpanContext().TraceID)
+	return nil
+}
+
+// tracedHandler returns a handler continuing the trace of the request in a
+// server span named name, within which it calls handle.
+func tracedHandler(tracer trace.Tracer, name string, handle func(context.Context) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), r.Header)
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(name, "/", r)...),
+		)
+		defer span.End()
+
+		if err := handle(ctx); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(http.StatusBadGateway))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(http.StatusOK))
+		_, _ = io.WriteString(w, "ok")
+	})
+}
+
+// tracedGet sends a GET request to url in a client span, injecting the
+// span's context into the request headers.
+func tracedGet(ctx context.Context, tracer trace.Tracer, url string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	ctx, span := tracer.Start(ctx, "HTTP GET",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, req.Header)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GET %s: %s", url, resp.Status)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 11, 13, 10, 0, 0, 0, time.UTC)
+	c := newFakeClock(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Fatalf("Now() = %s, want %s", got, start)
+	}
+	var slept time.Duration
+	for _, d := range []time.Duration{17 * time.Second, time.Millisecond, 0, 87 * time.Millisecond} {
+		before := c.Now()
+		c.Sleep(d)
+		slept += d
+		if got := c.Now().Sub(before); got != d {
+			t.Errorf("Sleep(%s) advanced the clock by %s", d, got)
+		}
+	}
+	if got := c.Now().Sub(start); got != slept {
+		t.Errorf("clock advanced by %s in total, want %s", got, slept)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Full method names of the OTLP export RPCs.
+const (
+	traceExportMethod   = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	metricsExportMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+)
+
+// fakeCollector is a minimal in-process stand-in for the OpenTelemetry
+// collector. It accepts OTLP trace and metric exports over gRPC, counts the
+// spans and metrics they contain and discards them.
+//
+// The generated OTLP service code vendored by the exporter is internal to
+// its module, so the collector handles the export RPCs as unknown services
+// on raw message bytes and walks the protobuf encoding itself.
+type fakeCollector struct {
+	server  *grpc.Server
+	spans   int64
+	metrics int64
+}
+
+// startFakeCollector starts a fake collector listening on addr.
+func startFakeCollector(addr string) (*fakeCollector, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	c := serveFakeCollector(lis)
+	log.Printf("fake collector listening on %s", lis.Addr())
+	return c, nil
+}
+
+// serveFakeCollector starts a fake collector accepting connections on lis.
+func serveFakeCollector(lis net.Listener) *fakeCollector {
+	c := &fakeCollector{}
+	c.server = grpc.NewServer(
+		grpc.CustomCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(c.handle),
+	)
+	go func() {
+		if err := c.server.Serve(lis); err != nil {
+			log.Printf("fake collector: %v", err)
+		}
+	}()
+	return c
+}
+
+// stop closes the listener and waits for in-flight exports to complete.
+func (c *fakeCollector) stop() {
+	c.server.GracefulStop()
+	log.Printf("fake collector: received %d spans and %d metrics in total",
+		atomic.LoadInt64(&c.spans), atomic.LoadInt64(&c.metrics))
+}
+
+func (c *fakeCollector) handle(_ interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	var req []byte
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+
+	// Both export requests nest their items the same way: the request's
+	// field 1 holds the per-resource messages, their field 2 the
+	// per-library messages and those, in turn, the items in field 2.
+	n, err := countNested(req, 1, 2, 2)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "malformed request: %v", err)
+	}
+	switch method {
+	case traceExportMethod:
+		atomic.AddInt64(&c.spans, n)
+		log.Printf("fake collector: received %d spans", n)
+	case metricsExportMethod:
+		atomic.AddInt64(&c.metrics, n)
+		log.Printf("fake collector: received %d metrics", n)
+	default:
+		return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+
+	// An empty message is a valid encoding of both export responses.
+	resp := []byte{}
+	return stream.SendMsg(&resp)
+}
+
+// countNested counts the occurrences of the innermost field of path within
+// the protobuf message b, where each element of path is the number of a
+// length-delimited field nested in the previous one.
+func countNested(b []byte, path ...protowire.Number) (int64, error) {
+	var n int64
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return 0, protowire.ParseError(l)
+		}
+		b = b[l:]
+		if num != path[0] || typ != protowire.BytesType {
+			l = protowire.ConsumeFieldValue(num, typ, b)
+			if l < 0 {
+				return 0, protowire.ParseError(l)
+			}
+			b = b[l:]
+			continue
+		}
+
+		v, l := protowire.ConsumeBytes(b)
+		if l < 0 {
+			return 0, protowire.ParseError(l)
+		}
+		b = b[l:]
+		if len(path) == 1 {
+			n++
+			continue
+		}
+		m, err := countNested(v, path[1:]...)
+		if err != nil {
+			return 0, err
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// rawCodec passes gRPC messages through as raw bytes.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) String() string {
+	return "raw"
+}
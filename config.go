@@ -0,0 +1,624 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/label"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// config holds the settings of the example that can be changed from the
+// command line.
+type config struct {
+	// configFile holds further settings, see applyConfigFile.
+	configFile string
+
+	// exporter is the name of the exporter, see exporters.
+	exporter string
+	// listExporters prints the available exporters and exits.
+	listExporters bool
+
+	// collectorAddr is the host:port of the collector's OTLP gRPC receiver.
+	collectorAddr string
+	// dialer, when not nil, connects to the collectors in place of the
+	// network, for instance to an in-process collector in tests. It has no
+	// flag.
+	dialer func(ctx context.Context, addr string) (net.Conn, error)
+	// fanoutAddrs are the addresses of further collectors receiving a copy
+	// of every span.
+	fanoutAddrs stringList
+	// alsoStdout prints every exported span to stdout as well.
+	alsoStdout bool
+
+	tracerName string
+
+	// meterName is the instrumentation name of the request metrics, and
+	// lineMeterName that of the line metrics.
+	meterName     string
+	lineMeterName string
+
+	// Span limits. The SDK enforces the count limit itself and evicts the
+	// oldest attributes once a span holds too many; the value length limit
+	// is enforced by truncatingSpanProcessor when the span ends, and a
+	// value length limit of zero disables truncation.
+	attributeCountLimit       int
+	attributeValueLengthLimit int
+	// spanLimitsDemo records a span exceeding both limits at startup.
+	spanLimitsDemo bool
+
+	// sampleRatio is the fraction of new traces that are sampled.
+	sampleRatio float64
+	// logSampling logs the sampling decisions, rate limited.
+	logSampling bool
+
+	// samplingPriority is set as the sampling.priority baggage member of
+	// every request when positive, forcing all of them to be sampled.
+	samplingPriority int
+
+	// smokeTest sends a single span and exits, failing if setting up the
+	// providers takes longer than smokeTestTimeout.
+	smokeTest        bool
+	smokeTestTimeout time.Duration
+	// chainDemo records a trace across in-process services and exits.
+	chainDemo bool
+	// clockSkew, when positive, records a trace with timestamps skewed by
+	// it and exits.
+	clockSkew time.Duration
+	// injectErrorTrace records a canonical error trace before the run,
+	// errorTraceOnly exits right after it.
+	injectErrorTrace bool
+	errorTraceOnly   bool
+	// dumpHeaders prints the propagation headers of a request and exits.
+	dumpHeaders bool
+	// verifyPropagation checks the propagator configuration and exits.
+	verifyPropagation bool
+
+	// userAgent is sent ahead of gRPC's own user agent, which is sent alone
+	// when it is empty.
+	userAgent string
+
+	// grpcLB is the gRPC load balancing policy of the collector
+	// connections, pick_first or round_robin.
+	grpcLB string
+
+	// gRPC keepalive parameters of the collector connection. The defaults
+	// stay within the enforcement policy of a default gRPC server, which
+	// closes connections that ping more often than every five minutes or
+	// while no RPC is in flight.
+	keepaliveTime                time.Duration
+	keepaliveTimeout             time.Duration
+	keepalivePermitWithoutStream bool
+
+	// metricsFile is a file every metric export is appended to as JSON,
+	// if set.
+	metricsFile string
+
+	// pushPeriod is the interval between metric exports. It is reloaded
+	// from configFile on SIGHUP.
+	pushPeriod time.Duration
+
+	// exportChunkSize is the maximum number of spans sent to the collector
+	// in a single export, or zero for no limit beyond the batch size.
+	exportChunkSize int
+
+	// failExportRate is the fraction of span exports failed on purpose, a
+	// debugging aid only.
+	failExportRate float64
+
+	// flushEvery force flushes the batch span processor after every that
+	// many sampled spans, or never when zero.
+	flushEvery int
+
+	// spanQueueWarnAt is the utilization of the span queue above which a
+	// warning is logged, or zero to never warn.
+	spanQueueWarnAt float64
+
+	// pingInterval is the interval between pings of the collector, which
+	// are disabled when zero.
+	pingInterval time.Duration
+
+	// Timeouts bounding each trace and metric export. Exports that time
+	// out are reported to the error handler.
+	traceExportTimeout  time.Duration
+	metricExportTimeout time.Duration
+
+	// debugGoroutines sets the goroutine.id attribute on the spans of the
+	// simulated requests and stress patterns.
+	debugGoroutines bool
+
+	// syntheticTimestamps times the simulated requests with a fake clock
+	// instead of sleeping.
+	syntheticTimestamps bool
+
+	// stdin runs a request for each line read from stdin, ending the run at
+	// the end of the input.
+	stdin bool
+
+	// traceparent is a W3C traceparent header whose trace the first
+	// request continues, if set.
+	traceparent string
+
+	// redactKeys are the words that attribute keys whose values are
+	// redacted from spans contain, such as password or token.
+	redactKeys stringList
+
+	// syntheticTraffic is the value of the traffic.synthetic attribute of
+	// every span.
+	syntheticTraffic bool
+
+	// tagIterations sets the sequence number of every request within the
+	// run as the loop.iteration attribute of its root span.
+	tagIterations bool
+
+	// startupDelay is waited for once the providers are set up, before the
+	// run starts, and does not count towards its duration.
+	startupDelay time.Duration
+
+	// iterations and duration bound the run, which ends as soon as either
+	// is reached. Zero means no bound.
+	iterations int
+	duration   time.Duration
+
+	// concurrency is the number of goroutines driving the loop, each
+	// running its own stream of requests.
+	concurrency int
+
+	// workers is the size of the worker pool f1 hands its follow-up work to.
+	// When zero, f1 calls f2 directly.
+	workers int
+
+	// Vendor-specific tracestate entry attached to every request. An empty
+	// key disables it.
+	traceStateKey   string
+	traceStateValue string
+
+	// aggregation of the value recorders, one of exact, histogram or
+	// exponential.
+	aggregation string
+
+	// latencyUnit is the unit the request latency is recorded in, a key of
+	// latencyUnits.
+	latencyUnit string
+
+	// temporality of the exported metrics, cumulative or delta.
+	temporality string
+
+	// slowThreshold is the simulated latency above which a request records
+	// a SlowPath span event, or zero to never record it.
+	slowThreshold time.Duration
+
+	// sampledLatencyOnly records the latency of sampled requests only.
+	sampledLatencyOnly bool
+
+	// boundInstruments records measurements through bound instruments
+	// rather than passing the labels on every call.
+	boundInstruments bool
+
+	// metricLabelKeys lists the labels kept on exported metrics. All labels
+	// are kept when it is empty.
+	metricLabelKeys stringList
+
+	// debugProbability is the probability of a request being flagged for
+	// debugging with the debug baggage member.
+	debugProbability float64
+
+	// randomSpanNames names the span of each request after a random one of
+	// spanNames, or of a default set of names when it is empty.
+	randomSpanNames bool
+	spanNames       stringList
+
+	// spanNameRules normalize the path segments of span names.
+	spanNameRules spanNameRules
+
+	// randomAttributes is the number of random attributes set on each span
+	// of the simulated requests, at most maxRandomAttributes.
+	randomAttributes int
+
+	// spanAttributes are set on every span of the simulated requests.
+	spanAttributes jsonAttributes
+
+	// tenants are the simulated tenants, one of which is picked at random
+	// for each request. Requests have no tenant when it is empty.
+	tenants stringList
+
+	// attrValues are the candidate values read from attrValuesFile, one of
+	// which is set as the attrValuesKey attribute of every span. Spans have
+	// no such attribute when no file is given.
+	attrValuesFile string
+	attrValuesKey  string
+	attrValues     []string
+
+	// printSampleRate is the fraction of generated lines printed to stdout.
+	// All lines are counted regardless.
+	printSampleRate float64
+
+	// lineLengthBuckets are the edges of the ranges line lengths are
+	// reported in.
+	lineLengthBuckets buckets
+
+	// stress selects a stress pattern to record instead of the simulated
+	// requests, see runStress.
+	stress      string
+	stressSpans int
+	stressWidth int
+
+	// region and timezone are set as the deployment.region and
+	// host.timezone resource attributes. The region is omitted and the
+	// timezone is the local one when empty.
+	region   string
+	timezone string
+
+	// detectCloud enables detection of the cloud provider and region.
+	detectCloud bool
+
+	// resourceAttributeLimit is the number of resource attributes above
+	// which a warning is logged, or the lowest-priority attributes are
+	// dropped if trimResource is set. Zero disables the limit.
+	resourceAttributeLimit int
+	trimResource           bool
+
+	// enableLogs emits a log record correlated with the span of every
+	// request.
+	enableLogs bool
+
+	// runFakeCollector starts an in-process fake collector on
+	// collectorAddr.
+	runFakeCollector bool
+
+	// adminAddr is the listen address of the admin HTTP server, which is
+	// disabled when empty.
+	adminAddr string
+}
+
+// parseFlags registers the command line flags and parses them into a config.
+func parseFlags() config {
+	var cfg config
+	collectorAddr := "0.0.0.0:55680"
+	if addr, ok := os.LookupEnv("OTEL_AGENT_ENDPOINT"); ok {
+		collectorAddr = addr
+	}
+	flag.StringVar(&cfg.exporter, "exporter", "otlp", "exporter to send telemetry with, see --list-exporters")
+	flag.BoolVar(&cfg.listExporters, "list-exporters", false, "print the available exporters, then exit")
+	flag.StringVar(&cfg.collectorAddr, "collector-addr", collectorAddr, "address of the collector's OTLP gRPC receiver, defaults to $OTEL_AGENT_ENDPOINT if set")
+	flag.Var(&cfg.fanoutAddrs, "fanout-collector-addr", "address of a further collector receiving a copy of every span, may be repeated")
+	flag.BoolVar(&cfg.alsoStdout, "also-stdout", false, "print every span exported to the collectors to stdout as well")
+	flag.StringVar(&cfg.tracerName, "tracer-name", "test-tracer", "instrumentation name of the tracer")
+	flag.StringVar(&cfg.meterName, "meter-name", "test-meter", "instrumentation name of the meter of the request metrics")
+	flag.StringVar(&cfg.lineMeterName, "line-meter-name", "test-meter-lines", "instrumentation name of the meter of the line metrics")
+	flag.IntVar(&cfg.attributeCountLimit, "span-attribute-count-limit", sdktrace.DefaultMaxAttributesPerSpan, "maximum number of attributes kept per span")
+	flag.IntVar(&cfg.attributeValueLengthLimit, "span-attribute-value-length-limit", 1024, "maximum length in bytes of string attribute values, 0 for no limit")
+	flag.BoolVar(&cfg.spanLimitsDemo, "span-limits-demo", false, "record a span exceeding both span attribute limits at startup")
+	flag.BoolVar(&cfg.chainDemo, "chain-demo", false, "record a trace across a chain of two in-process HTTP services, then exit")
+	flag.DurationVar(&cfg.clockSkew, "clock-skew", 0, "DEBUG ONLY: record a trace with child and end timestamps skewed by this much, then exit")
+	flag.BoolVar(&cfg.injectErrorTrace, "inject-error-trace", false, "record one canonical trace with a failed span before the run")
+	flag.BoolVar(&cfg.errorTraceOnly, "error-trace-only", false, "exit after the trace of --inject-error-trace instead of running")
+	flag.BoolVar(&cfg.dumpHeaders, "dump-headers", false, "print the propagation headers an outbound request would carry, then exit")
+	flag.BoolVar(&cfg.verifyPropagation, "verify-propagation", false, "check that the propagator round-trips the span context, then exit")
+	flag.Float64Var(&cfg.sampleRatio, "sample-ratio", 1, "fraction of traces to sample")
+	flag.BoolVar(&cfg.logSampling, "log-sampling", false, fmt.Sprintf("log the sampling decision of every new span, at most %d times a second", maxSamplingLogsPerSecond))
+	flag.IntVar(&cfg.samplingPriority, "sampling-priority", 0, "sampling.priority baggage value of every request, a positive value forces sampling")
+	flag.BoolVar(&cfg.smokeTest, "smoke-test", false, "send a single test span and exit non-zero if it could not be exported")
+	flag.DurationVar(&cfg.smokeTestTimeout, "smoke-test-timeout", 10*time.Second, "how long --smoke-test waits for the connection to the collector")
+	flag.StringVar(&cfg.grpcLB, "grpc-lb", "pick_first", "gRPC load balancing policy across the collector addresses, pick_first or round_robin; round_robin needs a dns:/// collector address to resolve all replicas")
+	flag.StringVar(&cfg.userAgent, "user-agent", "", "user agent sent to the collector ahead of gRPC's own one")
+	flag.DurationVar(&cfg.keepaliveTime, "grpc-keepalive-time", 5*time.Minute, "interval of inactivity after which the collector connection is pinged, 0 to disable keepalive")
+	flag.DurationVar(&cfg.keepaliveTimeout, "grpc-keepalive-timeout", 20*time.Second, "time to wait for a keepalive ping to be acknowledged before closing the connection")
+	flag.BoolVar(&cfg.keepalivePermitWithoutStream, "grpc-keepalive-permit-without-stream", false, "send keepalive pings even when no export is in flight")
+	flag.DurationVar(&cfg.pushPeriod, "push-period", 7*time.Second, "interval between metric exports")
+	flag.StringVar(&cfg.metricsFile, "metrics-file", "", "file every metric export is appended to as a line of OTLP-style JSON")
+	flag.IntVar(&cfg.exportChunkSize, "export-chunk-size", 0, "maximum number of spans per export, larger batches are split, 0 for no limit")
+	flag.Float64Var(&cfg.failExportRate, "fail-export-rate", 0, "DEBUG ONLY: fraction of span exports to fail without sending them")
+	flag.IntVar(&cfg.flushEvery, "flush-every", 0, "force flush the span batch after every that many sampled spans, 0 to only export on the batch timeout and size")
+	flag.Float64Var(&cfg.spanQueueWarnAt, "span-queue-warn-at", 0.8, "fraction of the span queue in use above which a warning is logged, 0 to never warn")
+	flag.DurationVar(&cfg.pingInterval, "collector-ping-interval", 0, "interval between pings of the collector reported as appdemo/collector_up, 0 to disable")
+	exportTimeout := flag.Duration("export-timeout", 10*time.Second, "maximum duration of a single export, unless overridden per signal")
+	flag.DurationVar(&cfg.traceExportTimeout, "trace-export-timeout", 0, "maximum duration of a single trace export, defaults to the metric export timeout if only that is set")
+	flag.DurationVar(&cfg.metricExportTimeout, "metric-export-timeout", 0, "maximum duration of a single metric export, defaults to the trace export timeout if only that is set")
+	flag.BoolVar(&cfg.debugGoroutines, "debug-goroutines", false, "set the goroutine.id attribute on spans to tell apart the goroutines producing them")
+	flag.BoolVar(&cfg.syntheticTimestamps, "synthetic-timestamps", false, "time spans by the simulated latency without sleeping, their timestamps run ahead of the wall clock")
+	flag.StringVar(&cfg.traceparent, "traceparent", "", "W3C traceparent header of a trace the first request continues as a child of")
+	flag.Var(&cfg.redactKeys, "redact-keys", "comma separated words, such as password,token, redacting the values of span attributes whose keys contain them, ignoring case")
+	flag.BoolVar(&cfg.syntheticTraffic, "synthetic-traffic", true, "value of the traffic.synthetic attribute set on every span")
+	flag.BoolVar(&cfg.tagIterations, "tag-iterations", false, "set the sequence number of every request as the loop.iteration attribute of its root span")
+	flag.BoolVar(&cfg.stdin, "stdin", false, "run a request for each line read from stdin, with the line as the input.line attribute")
+	flag.IntVar(&cfg.iterations, "iterations", 0, "number of requests to run before exiting, 0 for no limit")
+	flag.DurationVar(&cfg.duration, "duration", 0, "time to run requests for before exiting, 0 for no limit")
+	flag.DurationVar(&cfg.startupDelay, "startup-delay", 0, "time to wait once the providers are set up before starting the run")
+	flag.IntVar(&cfg.concurrency, "concurrency", 1, "number of goroutines driving the loop concurrently")
+	flag.IntVar(&cfg.workers, "workers", 0, "number of worker goroutines running the nested work, 0 to run it inline")
+	flag.StringVar(&cfg.traceStateKey, "tracestate-key", "", "key of the tracestate entry propagated with every request, for example appdemo, disabled when empty")
+	flag.StringVar(&cfg.traceStateValue, "tracestate-value", "example", "value of the tracestate entry propagated with every request")
+	flag.StringVar(&cfg.latencyUnit, "latency-unit", "ms", "unit the request latency is recorded in: ms, s or ns")
+	flag.StringVar(&cfg.aggregation, "aggregation", "exact", "aggregation of the value recorders: exact, histogram or exponential")
+	flag.StringVar(&cfg.temporality, "temporality", "cumulative", "temporality of the exported metrics: cumulative or delta")
+	flag.DurationVar(&cfg.slowThreshold, "slow-threshold", 5*time.Second, "simulated latency above which a request records a SlowPath span event, 0 to disable")
+	flag.BoolVar(&cfg.sampledLatencyOnly, "sampled-latency-only", false, "record the latency of sampled requests only, biasing the latency metric towards them")
+	flag.BoolVar(&cfg.boundInstruments, "bound-instruments", true, "record through instruments bound to their labels, or pass the labels with every measurement when false")
+	flag.Var(&cfg.metricLabelKeys, "metric-label-keys", "comma separated label keys kept on exported metrics, all labels are kept when unset")
+	flag.StringVar(&cfg.stress, "stress", "", "record a single trace in a stress pattern and exit: deep or wide")
+	flag.IntVar(&cfg.stressSpans, "stress-spans", 500, "number of child spans of the deep stress pattern")
+	flag.IntVar(&cfg.stressWidth, "stress-width", 100, "number of concurrent child spans of the wide stress pattern")
+	flag.Float64Var(&cfg.debugProbability, "debug-probability", 0, "probability of a request being flagged as debug=true in its baggage and span attributes")
+	flag.BoolVar(&cfg.randomSpanNames, "random-span-names", false, "name the span of each request after a random one of --span-names, or of a default set")
+	cfg.spanNameRules.rules = defaultSpanNameRules
+	flag.Var(&cfg.spanNameRules, "span-name-rule", "pattern=placeholder rule replacing the span name path segments the pattern matches in full, may be repeated; replaces the default rules for numeric IDs and UUIDs")
+	flag.Var(&cfg.spanNames, "span-names", "comma separated span names picked from with --random-span-names")
+	flag.Var(&cfg.spanAttributes, "span-attrs", `JSON object of string, number and boolean attributes set on each request span, such as {"key":"value","n":5}`)
+	flag.IntVar(&cfg.randomAttributes, "random-attrs", 0, fmt.Sprintf("number of randomly keyed and valued attributes set on each request span, at most %d", maxRandomAttributes))
+	flag.StringVar(&cfg.attrValuesFile, "attr-values-file", "", "file of attribute values, one per line, one of which is set on every span")
+	flag.StringVar(&cfg.attrValuesKey, "attr-values-key", "appdemo.value", "key of the attribute set from --attr-values-file")
+	flag.Var(&cfg.tenants, "tenants", "comma separated tenant IDs, one of which is set as tenant.id on each request")
+	flag.Float64Var(&cfg.printSampleRate, "print-sample-rate", 1, "fraction of the generated lines printed to stdout, all of them are still counted")
+	cfg.lineLengthBuckets = buckets{100, 500, 1000}
+	flag.Var(&cfg.lineLengthBuckets, "line-length-buckets", "comma separated ascending edges of the ranges line lengths are reported in")
+	flag.StringVar(&cfg.region, "region", "", "deployment.region resource attribute, omitted when empty")
+	flag.StringVar(&cfg.timezone, "timezone", "", "IANA name of the host.timezone resource attribute, the local time zone when empty")
+	flag.BoolVar(&cfg.detectCloud, "detect-cloud", false, "detect the cloud provider and region from the environment")
+	flag.IntVar(&cfg.resourceAttributeLimit, "resource-attribute-limit", 128, "number of resource attributes above which a warning is logged, 0 for no limit")
+	flag.BoolVar(&cfg.trimResource, "trim-resource", false, "drop the lowest-priority resource attributes past the resource attribute limit")
+	flag.BoolVar(&cfg.enableLogs, "enable-logs", false, "emit a log record correlated with the active span for every request")
+	flag.BoolVar(&cfg.runFakeCollector, "run-fake-collector", false, "run an in-process fake collector on the collector address that counts what it receives")
+	flag.StringVar(&cfg.adminAddr, "admin-addr", "", "listen address of the admin HTTP server exposing /debug and /status, and /pause and /resume to POST to, disabled when empty")
+	flag.StringVar(&cfg.configFile, "config", "", "file of name=value flag settings, one per line, overridden by the command line")
+	flag.Parse()
+	if cfg.configFile != "" {
+		if err := applyConfigFile(cfg.configFile); err != nil {
+			log.Fatalf("failed to load config file: %v", err)
+		}
+	}
+
+	if cfg.attrValuesFile != "" {
+		values, err := readValuesFile(cfg.attrValuesFile)
+		if err != nil {
+			log.Fatalf("failed to load attribute values: %v", err)
+		}
+		cfg.attrValues = values
+	}
+
+	// Unknown exporters are reported by newProviders.
+	if info, err := lookupExporter(cfg.exporter); err == nil && !info.collector {
+		ignoreCollectorFlags(&cfg)
+		warnLegacyOTLPPort(cfg.fanoutAddrs)
+	} else {
+		warnLegacyOTLPPort(append([]string{cfg.collectorAddr}, cfg.fanoutAddrs...))
+	}
+
+	if _, ok := latencyUnits[cfg.latencyUnit]; !ok {
+		log.Fatalf("unknown latency unit %q, want ms, s or ns", cfg.latencyUnit)
+	}
+
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+
+	switch {
+	case cfg.randomAttributes < 0:
+		cfg.randomAttributes = 0
+	case cfg.randomAttributes > maxRandomAttributes:
+		log.Printf("--random-attrs capped to %d", maxRandomAttributes)
+		cfg.randomAttributes = maxRandomAttributes
+	}
+
+	// A timeout set for one signal only applies to the other one as well.
+	switch {
+	case cfg.traceExportTimeout == 0 && cfg.metricExportTimeout == 0:
+		cfg.traceExportTimeout = *exportTimeout
+		cfg.metricExportTimeout = *exportTimeout
+	case cfg.traceExportTimeout == 0:
+		cfg.traceExportTimeout = cfg.metricExportTimeout
+	case cfg.metricExportTimeout == 0:
+		cfg.metricExportTimeout = cfg.traceExportTimeout
+	}
+	return cfg
+}
+
+// legacyOTLPPort is the port OTLP receivers listened on before 4317 was
+// assigned, which current collectors no longer listen on by default.
+const legacyOTLPPort = "55680"
+
+// warnLegacyOTLPPort logs a warning if any of the collector addresses uses
+// the legacy OTLP port, once for all of them.
+func warnLegacyOTLPPort(addrs []string) {
+	for _, addr := range addrs {
+		if _, port, err := net.SplitHostPort(addr); err == nil && port == legacyOTLPPort {
+			log.Printf("collector address %s uses the deprecated OTLP port %s, current collectors receive OTLP gRPC on port 4317", addr, legacyOTLPPort)
+			return
+		}
+	}
+}
+
+// ignoreCollectorFlags disables the settings of cfg that only apply when
+// exporting to the collector at --collector-addr, warning about those that
+// were set.
+func ignoreCollectorFlags(cfg *config) {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if cfg.alsoStdout {
+		log.Printf("--also-stdout ignored, --exporter=%s does not send spans to a collector", cfg.exporter)
+		cfg.alsoStdout = false
+	}
+	if cfg.pingInterval > 0 {
+		log.Printf("--collector-ping-interval ignored, --exporter=%s has no collector to ping", cfg.exporter)
+		cfg.pingInterval = 0
+	}
+	if set["temporality"] {
+		log.Printf("--temporality ignored, --exporter=%s picks the temporality of each instrument itself", cfg.exporter)
+	}
+}
+
+// readValuesFile reads the non-blank lines of the file at path, each one a
+// value. The file is read once up front, so values are picked from memory.
+func readValuesFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			values = append(values, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s: no values", path)
+	}
+	return values, nil
+}
+
+// configSetting is a single flag setting read from a config file.
+type configSetting struct {
+	name, value string
+}
+
+// readConfigFile reads the flag settings in the file at path. Each line
+// holds a flag name without leading dashes and its value separated by '=',
+// and blank lines and lines starting with '#' are ignored.
+func readConfigFile(path string) ([]configSetting, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var settings []configSetting
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: missing '='", path, n)
+		}
+		settings = append(settings, configSetting{
+			name:  strings.TrimSpace(line[:i]),
+			value: strings.TrimSpace(line[i+1:]),
+		})
+	}
+	return settings, scanner.Err()
+}
+
+// applyConfigFile sets the flags listed in the file at path, in order.
+// Flags given on the command line take precedence and are left unchanged.
+func applyConfigFile(path string) error {
+	settings, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	onCommandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+	for _, s := range settings {
+		if onCommandLine[s.name] {
+			continue
+		}
+		if err := flag.Set(s.name, s.value); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, s.name, err)
+		}
+	}
+	return nil
+}
+
+// stringList is a flag.Value holding a list of strings. Values are comma
+// separated and the flag may be repeated to append to the list.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// jsonAttributes is a flag.Value holding attributes given as a JSON object.
+// Strings and booleans keep their type, and numbers become integer
+// attributes if they are whole and float attributes otherwise. Other values
+// are rejected.
+type jsonAttributes []label.KeyValue
+
+func (a *jsonAttributes) String() string {
+	kvs := make([]string, len(*a))
+	for i, kv := range *a {
+		kvs[i] = fmt.Sprintf("%s=%s", kv.Key, kv.Value.Emit())
+	}
+	return strings.Join(kvs, ",")
+}
+
+func (a *jsonAttributes) Set(value string) error {
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return fmt.Errorf("invalid JSON object: %w", err)
+	}
+	if obj == nil || dec.More() {
+		return fmt.Errorf("want a single JSON object")
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]label.KeyValue, len(keys))
+	for i, k := range keys {
+		switch v := obj[k].(type) {
+		case string:
+			attrs[i] = label.String(k, v)
+		case bool:
+			attrs[i] = label.Bool(k, v)
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				attrs[i] = label.Int64(k, n)
+			} else if f, err := v.Float64(); err == nil {
+				attrs[i] = label.Float64(k, f)
+			} else {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		default:
+			return fmt.Errorf("%s: unsupported value %v, want a string, number or boolean", k, v)
+		}
+	}
+	*a = attrs
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/stats"
+)
+
+// connStateTracker is a gRPC stats handler that follows the state of the
+// exporter's connection to the collector. The OTLP exporter dials and owns
+// its ClientConn without exposing it, so the state is derived from the
+// connection events gRPC reports to the handler instead.
+type connStateTracker struct {
+	mu    sync.Mutex
+	state connectivity.State
+	since time.Time
+}
+
+var _ stats.Handler = (*connStateTracker)(nil)
+
+func newConnStateTracker() *connStateTracker {
+	return &connStateTracker{
+		state: connectivity.Connecting,
+		since: time.Now(),
+	}
+}
+
+// State returns the current connection state and when it was entered.
+func (t *connStateTracker) State() (connectivity.State, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state, t.since
+}
+
+func (t *connStateTracker) set(state connectivity.State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state == state {
+		return
+	}
+	t.state = state
+	t.since = time.Now()
+	log.Printf("collector connection state: %s", state)
+}
+
+func (t *connStateTracker) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (t *connStateTracker) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (t *connStateTracker) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (t *connStateTracker) HandleConn(_ context.Context, s stats.ConnStats) {
+	switch s.(type) {
+	case *stats.ConnBegin:
+		t.set(connectivity.Ready)
+	case *stats.ConnEnd:
+		t.set(connectivity.TransientFailure)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/label"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// debugKey is the baggage member flagging a request for debugging, and the
+// span attribute set on all spans of such a request.
+const debugKey = label.Key("debug")
+
+// withRandomDebug flags the request of ctx for debugging with the given
+// probability. The flag is baggage, so it reaches every span of the request,
+// also across process boundaries, since baggage is propagated.
+func withRandomDebug(ctx context.Context, rng *rand.Rand, probability float64) context.Context {
+	if rng.Float64() >= probability {
+		return ctx
+	}
+	return baggage.ContextWithValues(ctx, debugKey.Bool(true))
+}
+
+// debugSpanProcessor sets the debug attribute on the spans started in a
+// context flagged for debugging. The context is only available to OnStart,
+// while attributes can only be changed in OnEnd, where each span data is a
+// copy of its own, so the flagged spans are remembered in between. It must
+// be registered ahead of the batch span processor.
+type debugSpanProcessor struct {
+	mu      sync.Mutex
+	flagged map[trace.SpanContext]struct{}
+}
+
+var _ sdktrace.SpanProcessor = (*debugSpanProcessor)(nil)
+
+func newDebugSpanProcessor() *debugSpanProcessor {
+	return &debugSpanProcessor{
+		flagged: make(map[trace.SpanContext]struct{}),
+	}
+}
+
+func (p *debugSpanProcessor) OnStart(parent context.Context, sd *export.SpanData) {
+	// Propagated baggage carries string values.
+	if baggage.Value(parent, debugKey).Emit() != "true" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flagged[sd.SpanContext] = struct{}{}
+}
+
+func (p *debugSpanProcessor) OnEnd(sd *export.SpanData) {
+	p.mu.Lock()
+	_, ok := p.flagged[sd.SpanContext]
+	delete(p.flagged, sd.SpanContext)
+	p.mu.Unlock()
+	if ok {
+		sd.Attributes = append(sd.Attributes, debugKey.Bool(true))
+	}
+}
+
+func (p *debugSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *debugSpanProcessor) ForceFlush() {}
@@ -0,0 +1,79 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The attributes of an exception, as the semantic conventions name them.
+const (
+	exceptionTypeKey       = label.Key("exception.type")
+	exceptionMessageKey    = label.Key("exception.message")
+	exceptionStacktraceKey = label.Key("exception.stacktrace")
+)
+
+// insufficientFundsError is the exception of the canonical error trace.
+type insufficientFundsError struct{}
+
+func (insufficientFundsError) Error() string {
+	return "payment declined: insufficient funds on account 4000-0000-0000-0002 (requested 129.99 EUR, available 42.00 EUR)"
+}
+
+// canonicalStacktrace is fixed so that every error trace looks the same.
+const canonicalStacktrace = `goroutine 1 [running]:
+main.(*paymentService).charge(0xc000010240, 0xc00001e0c0, 0x12, 0x3259)
+	/app/payment/service.go:87 +0x1c5
+main.(*checkoutHandler).ServeHTTP(0xc00000e028, 0x7a3e20, 0xc0000ae000, 0xc0000b2000)
+	/app/checkout/handler.go:54 +0x2b9
+net/http.serverHandler.ServeHTTP(0xc0000ae000, 0x7a3e20, 0xc0000ae000, 0xc0000b2000)
+	/usr/local/go/src/net/http/server.go:2843 +0xa3
+net/http.(*conn).serve(0xc0000a8000, 0x7a4a60, 0xc00009e040)
+	/usr/local/go/src/net/http/server.go:1925 +0x8ad`
+
+// injectErrorTrace records one canonical error trace, the same on every
+// call, to test how a backend displays failures:
+//
+//	Checkout        error status, sampling priority
+//	  ChargePayment error event with exception attributes, error status
+//
+// The root span carries a positive sampling priority, so that the trace is
+// sampled whatever the sampling ratio. injectErrorTrace reports whether it
+// was.
+func injectErrorTrace(ctx context.Context, tracer trace.Tracer) bool {
+	ctx, checkout := tracer.Start(ctx, "Checkout",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(samplingPriorityKey.Int(1)),
+	)
+	defer checkout.End()
+
+	_, charge := tracer.Start(ctx, "ChargePayment", trace.WithSpanKind(trace.SpanKindClient))
+	err := insufficientFundsError{}
+	charge.RecordError(err, trace.WithAttributes(
+		exceptionTypeKey.String("main.insufficientFundsError"),
+		exceptionMessageKey.String(err.Error()),
+		exceptionStacktraceKey.String(canonicalStacktrace),
+	))
+	// RecordError sets the error status without a description.
+	charge.SetStatus(codes.Error, err.Error())
+	charge.End()
+
+	checkout.SetStatus(codes.Error, "payment failed")
+	return checkout.SpanContext().IsSampled()
+}
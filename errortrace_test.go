@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestInjectErrorTrace(t *testing.T) {
+	exp := &recordingExporter{}
+	// As in newProviders, but with a ratio dropping every trace.
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler: sdktrace.ParentBased(prioritySampler{
+				Sampler: sdktrace.TraceIDRatioBased(0),
+			}),
+		}),
+		sdktrace.WithSyncer(exp),
+	)
+	if !injectErrorTrace(context.Background(), tp.Tracer("test")) {
+		t.Fatal("injectErrorTrace reported the trace as not sampled")
+	}
+
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+	if len(exp.spans) != 2 {
+		t.Fatalf("%d spans exported, want 2", len(exp.spans))
+	}
+	charge := exp.spans[0]
+	if charge.Name != "ChargePayment" {
+		t.Fatalf("first span exported is %s, want ChargePayment", charge.Name)
+	}
+	if charge.StatusCode != codes.Error || charge.StatusMessage == "" {
+		t.Errorf("ChargePayment status is %s %q, want an error with a description", charge.StatusCode, charge.StatusMessage)
+	}
+	for _, key := range []label.Key{exceptionTypeKey, exceptionMessageKey, exceptionStacktraceKey} {
+		if _, ok := attributeMap(charge.Attributes)[key]; ok {
+			t.Errorf("ChargePayment has the span attribute %s, want it on the error event only", key)
+		}
+	}
+	if len(charge.MessageEvents) != 1 {
+		t.Fatalf("ChargePayment has %d events, want 1", len(charge.MessageEvents))
+	}
+	attrs := attributeMap(charge.MessageEvents[0].Attributes)
+	for _, key := range []label.Key{exceptionTypeKey, exceptionMessageKey, exceptionStacktraceKey} {
+		if _, ok := attrs[key]; !ok {
+			t.Errorf("error event lacks the attribute %s", key)
+		}
+	}
+}
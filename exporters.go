@@ -0,0 +1,236 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	"google.golang.org/grpc"
+)
+
+// timeoutSpanExporter bounds every export with a deadline. The batch span
+// processor exports with a background context, so without it a single slow
+// export can stall the processor indefinitely.
+type timeoutSpanExporter struct {
+	export.SpanExporter
+	timeout time.Duration
+}
+
+func (e timeoutSpanExporter) ExportSpans(ctx context.Context, sds []*export.SpanData) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.SpanExporter.ExportSpans(ctx, sds)
+}
+
+// chunkingSpanExporter splits batches of more than size spans into several
+// exports, to stay below the maximum message size of the collector.
+type chunkingSpanExporter struct {
+	export.SpanExporter
+	size int
+}
+
+// chunkSpans returns exp exporting at most size spans at a time, or exp
+// itself if size is not positive.
+func chunkSpans(exp export.SpanExporter, size int) export.SpanExporter {
+	if size <= 0 {
+		return exp
+	}
+	return chunkingSpanExporter{SpanExporter: exp, size: size}
+}
+
+// ExportSpans exports sds in chunks, even if some of them fail.
+func (e chunkingSpanExporter) ExportSpans(ctx context.Context, sds []*export.SpanData) error {
+	var errs multiError
+	for len(sds) > 0 {
+		n := e.size
+		if n > len(sds) {
+			n = len(sds)
+		}
+		if err := e.SpanExporter.ExportSpans(ctx, sds[:n]); err != nil {
+			errs = append(errs, err)
+		}
+		sds = sds[n:]
+	}
+	return errs.errOrNil()
+}
+
+// errInjectedExportFailure is returned for the exports failingSpanExporter
+// fails on purpose.
+var errInjectedExportFailure = errors.New("injected export failure")
+
+// failingSpanExporter fails a random fraction of its exports without
+// exporting anything, to exercise error handling without a broken
+// collector. It is meant for debugging only.
+type failingSpanExporter struct {
+	export.SpanExporter
+	rate float64
+}
+
+// failExports returns exp failing the given fraction of exports, or exp
+// itself if rate is not positive.
+func failExports(exp export.SpanExporter, rate float64) export.SpanExporter {
+	if rate <= 0 {
+		return exp
+	}
+	return failingSpanExporter{SpanExporter: exp, rate: rate}
+}
+
+func (e failingSpanExporter) ExportSpans(ctx context.Context, sds []*export.SpanData) error {
+	if rand.Float64() < e.rate {
+		return errInjectedExportFailure
+	}
+	return e.SpanExporter.ExportSpans(ctx, sds)
+}
+
+// byteCountingSpanExporter adds the estimated size of every successful
+// export to a counter, labeled with the collector it was sent to. Only span
+// exports are counted: the counter is itself exported by the metric
+// pipeline, so counting metric exports as well would have every export
+// change the value the next one reports, and never settle even when idle.
+type byteCountingSpanExporter struct {
+	export.SpanExporter
+	bytes  metric.Int64Counter
+	labels []label.KeyValue
+}
+
+// countExportBytes returns exp adding the size of its exports to the
+// collector to bytes.
+func countExportBytes(exp export.SpanExporter, bytes metric.Int64Counter, collector string) export.SpanExporter {
+	return byteCountingSpanExporter{
+		SpanExporter: exp,
+		bytes:        bytes,
+		labels:       []label.KeyValue{label.String("collector", collector)},
+	}
+}
+
+func (e byteCountingSpanExporter) ExportSpans(ctx context.Context, sds []*export.SpanData) error {
+	if err := e.SpanExporter.ExportSpans(ctx, sds); err != nil {
+		return err
+	}
+	e.bytes.Add(ctx, int64(estimateExportSize(sds)), e.labels...)
+	return nil
+}
+
+// estimateExportSize approximates the size of the OTLP request exporting
+// sds. The exporter does not expose the size of the requests it sends, nor
+// the types it marshals them from, so the estimate adds up the size of the
+// fields rather than marshaling them. It ignores compression and the
+// framing of the protobuf fields, and the resource and instrumentation
+// library are counted once, as if all spans shared them.
+func estimateExportSize(sds []*export.SpanData) int {
+	if len(sds) == 0 {
+		return 0
+	}
+	var n int
+	if res := sds[0].Resource; res != nil {
+		n += attributesSize(res.Attributes())
+	}
+	n += len(sds[0].InstrumentationLibrary.Name) + len(sds[0].InstrumentationLibrary.Version)
+	for _, sd := range sds {
+		// Trace, span and parent span IDs, start and end time, and kind.
+		n += 16 + 8 + 8 + 8 + 8 + 1
+		n += len(sd.Name) + len(sd.StatusMessage) + attributesSize(sd.Attributes)
+		for _, e := range sd.MessageEvents {
+			n += 8 + len(e.Name) + attributesSize(e.Attributes)
+		}
+		for _, l := range sd.Links {
+			n += 16 + 8 + attributesSize(l.Attributes)
+		}
+	}
+	return n
+}
+
+func attributesSize(attrs []label.KeyValue) int {
+	var n int
+	for _, kv := range attrs {
+		n += len(kv.Key) + len(kv.Value.Emit())
+	}
+	return n
+}
+
+// multiSpanExporter sends every batch to all of its exporters, for example
+// to compare the output of several backends.
+type multiSpanExporter []export.SpanExporter
+
+// newFanoutExporter returns a multiSpanExporter sending to primary and to an
+// OTLP exporter for each of addrs.
+func newFanoutExporter(primary export.SpanExporter, addrs []string, dialOpts []grpc.DialOption) (multiSpanExporter, error) {
+	m := multiSpanExporter{primary}
+	for _, addr := range addrs {
+		exp, err := otlp.NewExporter(
+			otlp.WithInsecure(),
+			otlp.WithAddress(addr),
+			otlp.WithGRPCDialOption(dialOpts...),
+		)
+		if err != nil {
+			// The exporters created so far are not returned, so they are
+			// shut down here. The primary one belongs to the caller.
+			_ = m[1:].Shutdown(context.Background())
+			return nil, fmt.Errorf("failed to create exporter for %s: %w", addr, err)
+		}
+		m = append(m, exp)
+	}
+	return m, nil
+}
+
+// ExportSpans exports sds with every exporter, even if some of them fail.
+func (m multiSpanExporter) ExportSpans(ctx context.Context, sds []*export.SpanData) error {
+	var errs multiError
+	for _, e := range m {
+		if err := e.ExportSpans(ctx, sds); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.errOrNil()
+}
+
+// Shutdown shuts down every exporter, even if some of them fail.
+func (m multiSpanExporter) Shutdown(ctx context.Context) error {
+	var errs multiError
+	for _, e := range m {
+		if err := e.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.errOrNil()
+}
+
+// multiError combines the errors of several operations into one.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// errOrNil returns nil if m holds no errors, and m otherwise.
+func (m multiError) errOrNil() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
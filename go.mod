@@ -1,10 +1,12 @@
-module main
+module github.com/lumontec/opentelemetry-basic-example
 
 go 1.14
 
 require (
 	go.opentelemetry.io/otel v0.14.0
 	go.opentelemetry.io/otel/exporters/otlp v0.14.0
+	go.opentelemetry.io/otel/exporters/stdout v0.14.0
 	go.opentelemetry.io/otel/sdk v0.14.0
 	google.golang.org/grpc v1.32.0
+	google.golang.org/protobuf v1.23.0
 )
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+// goroutineIDKey is the span attribute identifying the goroutine a span
+// was started on.
+const goroutineIDKey = label.Key("goroutine.id")
+
+// lastGoroutineID is the last ID handed out by newGoroutineID. Go does not
+// expose goroutine identities, so the goroutines of the example number
+// themselves instead.
+var lastGoroutineID int64
+
+type goroutineIDContextKey struct{}
+
+// newGoroutineID returns a new goroutine ID.
+func newGoroutineID() int64 {
+	return atomic.AddInt64(&lastGoroutineID, 1)
+}
+
+// withGoroutineID returns a copy of ctx used on the goroutine with id.
+func withGoroutineID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, goroutineIDContextKey{}, id)
+}
+
+// onGoroutine is like withGoroutineID for a context handed to another
+// goroutine, but only if ctx already carries a goroutine ID, which is how
+// goroutine tracking is enabled.
+func onGoroutine(ctx context.Context, id int64) context.Context {
+	if _, ok := ctx.Value(goroutineIDContextKey{}).(int64); !ok {
+		return ctx
+	}
+	return withGoroutineID(ctx, id)
+}
+
+// goroutineID returns the goroutine.id attribute for the goroutine ctx is
+// used on, if any.
+func goroutineID(ctx context.Context) []label.KeyValue {
+	id, ok := ctx.Value(goroutineIDContextKey{}).(int64)
+	if !ok {
+		return nil
+	}
+	return []label.KeyValue{goroutineIDKey.Int64(id)}
+}
@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// inFlightSpanProcessor keeps track of the spans that have been started but
+// not ended yet, to find operations that are stuck.
+type inFlightSpanProcessor struct {
+	mu    sync.Mutex
+	spans map[trace.SpanContext]*export.SpanData
+}
+
+var _ sdktrace.SpanProcessor = (*inFlightSpanProcessor)(nil)
+
+func newInFlightSpanProcessor() *inFlightSpanProcessor {
+	return &inFlightSpanProcessor{
+		spans: make(map[trace.SpanContext]*export.SpanData),
+	}
+}
+
+func (p *inFlightSpanProcessor) OnStart(_ context.Context, sd *export.SpanData) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.spans[sd.SpanContext] = sd
+}
+
+func (p *inFlightSpanProcessor) OnEnd(sd *export.SpanData) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.spans, sd.SpanContext)
+}
+
+func (p *inFlightSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *inFlightSpanProcessor) ForceFlush() {}
+
+// dump logs the spans in flight, oldest first.
+func (p *inFlightSpanProcessor) dump() {
+	type inFlight struct {
+		name    string
+		sc      trace.SpanContext
+		started time.Time
+	}
+	p.mu.Lock()
+	spans := make([]inFlight, 0, len(p.spans))
+	for sc, sd := range p.spans {
+		// The name is read at start, a later SetName is not reflected.
+		spans = append(spans, inFlight{sd.Name, sc, sd.StartTime})
+	}
+	p.mu.Unlock()
+
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].started.Before(spans[j].started)
+	})
+	log.Printf("%d spans in flight", len(spans))
+	for _, s := range spans {
+		log.Printf("in flight: %s trace_id=%s span_id=%s elapsed=%s", s.name, s.sc.TraceID, s.sc.SpanID, time.Since(s.started))
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logRequest emits a log record for a request, correlated with the
+// request's span through its trace and span IDs. The SDK has no logs signal
+// nor an OTLP log exporter yet, so the records are written by the standard
+// logger, in a form a log collector can parse and link to the trace.
+func logRequest(span trace.Span, latencyMs float64, lines int) {
+	sc := span.SpanContext()
+	log.Printf("msg=%q latency_ms=%.3f lines=%d trace_id=%s span_id=%s",
+		"request executed", latencyMs, lines, sc.TraceID, sc.SpanID)
+}
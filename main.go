@@ -16,79 +16,343 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout"
 	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
 	"go.opentelemetry.io/otel/sdk/metric/controller/push"
 	"go.opentelemetry.io/otel/sdk/metric/processor/basic"
-	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/metric/processor/reducer"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/semconv"
 	"go.opentelemetry.io/otel/trace"
-	"google.golang.org/grpc"
+	"go.opentelemetry.io/otel/unit"
 )
 
-// Initializes an OTLP exporter, and configures the corresponding trace and
-// metric providers.
-func initProvider() func() {
+// providers are the telemetry providers of the example, ready for use
+// without being installed globally.
+type providers struct {
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+	// crashFlush exports the spans ended so far when the program is about
+	// to crash, leaving the tracer provider shut down.
+	crashFlush func()
+	// shutdown flushes and shuts down the providers and their exporter.
+	shutdown func()
+}
+
+// Initializes the configured exporter, and configures the corresponding
+// trace and metric providers as the global ones.
+func initProvider(cfg config, conn *connStateTracker) (*providers, error) {
+	p, err := newProviders(cfg, conn)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTextMapPropagator(p.propagator)
+	otel.SetTracerProvider(p.tracerProvider)
+	otel.SetMeterProvider(p.meterProvider)
+	if err := checkGlobalProviders(p.tracerProvider, p.meterProvider); err != nil {
+		p.shutdown()
+		return nil, err
+	}
+	return p, nil
+}
+
+// newProviders initializes the configured exporter and the trace and metric
+// providers exporting through it, leaving the global ones unchanged, so
+// that several configurations can be used side by side.
+func newProviders(cfg config, conn *connStateTracker) (_ *providers, err error) {
+	// The providers are created right at startup, so this is close enough
+	// to the start of the process.
+	processStart := time.Now()
 	ctx := context.Background()
 
-	// otelAgentAddr, ok := os.LookupEnv("OTEL_AGENT_ENDPOINT")
-	// if !ok {
-	collectorAddr := "0.0.0.0:55680"
-	// }
+	info, err := lookupExporter(cfg.exporter)
+	if err != nil {
+		return nil, err
+	}
+	exp, metricExp, err := info.new(cfg, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter: %w", err)
+	}
+	var metricFile *metricFileExporter
+	var fanout multiSpanExporter
+	// If a later step fails, the connections and files opened so far are
+	// closed again. The error of that step is the one reported.
+	defer func() {
+		if err == nil {
+			return
+		}
+		_ = exp.Shutdown(ctx)
+		if len(fanout) > 0 {
+			_ = fanout[1:].Shutdown(ctx)
+		}
+		if metricFile != nil {
+			_ = metricFile.Close()
+		}
+	}()
+	if cfg.metricsFile != "" {
+		if metricFile, err = newMetricFileExporter(metricExp, cfg.metricsFile); err != nil {
+			return nil, fmt.Errorf("failed to open metrics file: %w", err)
+		}
+		metricExp = metricFile
+	}
 
-	exp, err := otlp.NewExporter(
-		otlp.WithInsecure(),
-		otlp.WithAddress(collectorAddr),
-		otlp.WithGRPCDialOption(grpc.WithBlock()), // useful for testing
-	)
-	handleErr(err, "failed to create exporter")
+	traceExporter := timeoutSpanExporter{
+		SpanExporter: exp,
+		timeout:      cfg.traceExportTimeout,
+	}
+	if len(cfg.fanoutAddrs) > 0 {
+		fanout, err = newFanoutExporter(exp, cfg.fanoutAddrs, otlpDialOptions(cfg))
+		if err != nil {
+			return nil, err
+		}
+		traceExporter.SpanExporter = fanout
+	}
 
+	detectors := []resource.Detector{k8sDetector{}}
+	if cfg.detectCloud {
+		detectors = append(detectors, cloudDetector{})
+	}
+	// resource.New runs the SDK's default detectors, for the telemetry.sdk
+	// and host attributes and OTEL_RESOURCE_ATTRIBUTES, ahead of the given
+	// ones, so the defaults are part of res without merging them in. The
+	// pinned SDK has no resource.Default to merge with.
+	deployment, err := localeAttributes(cfg.region, cfg.timezone)
+	if err != nil {
+		return nil, err
+	}
+	if sha := commitSHA(); sha != "" {
+		log.Printf("commit: %s", sha)
+		deployment = append(deployment, vcsRevisionKey.String(sha))
+	} else {
+		log.Print("commit: unknown")
+	}
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
 			// the service name used to display traces in backends
 			semconv.ServiceNameKey.String("test-service"),
+			processStartTimeKey.String(processStart.Format(time.RFC3339Nano)),
 		),
+		resource.WithAttributes(deployment...),
+		resource.WithDetectors(detectors...),
+	)
+	if err != nil {
+		// A failing detector does not prevent the example from running. The
+		// resource holds the attributes of all detectors that succeeded,
+		// and those of detectors reporting a partial resource.
+		log.Printf("resource detection incomplete, continuing with %s: %v", res, err)
+	}
+	res = limitResource(res, cfg.resourceAttributeLimit, cfg.trimResource)
+
+	// The metric pipeline is set up ahead of the tracer provider, so span
+	// processors can record into it. It is started once both are ready.
+	aggSelector, err := aggregatorSelector(cfg.aggregation, latencyUnits[cfg.latencyUnit])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aggregator selector: %w", err)
+	}
+	var checkpointer export.Checkpointer = basic.New(
+		aggSelector,
+		metricExp,
+	)
+	if len(cfg.metricLabelKeys) > 0 {
+		checkpointer = reducer.New(newLabelKeepSelector(cfg.metricLabelKeys), checkpointer)
+	}
+	pusher := push.New(
+		checkpointer,
+		metricExp,
+		push.WithPeriod(cfg.pushPeriod),
+		push.WithTimeout(cfg.metricExportTimeout),
+	)
+	pushClock := &adjustableClock{}
+	pusher.SetClock(pushClock)
+	meter := pusher.MeterProvider().Meter(cfg.meterName)
+
+	// The bytes sent are counted per collector, so each member of the
+	// fan-out is wrapped rather than the fan-out itself.
+	exportBytes := metric.Must(meter).NewInt64Counter(
+		"appdemo/export_bytes_total",
+		metric.WithDescription("The estimated number of bytes of spans exported to each collector"),
+		metric.WithUnit(unit.Bytes),
 	)
-	handleErr(err, "failed to create resource")
+	// An exporter that does not send to --collector-addr is not counted.
+	if len(fanout) > 0 {
+		for i := range fanout {
+			if i == 0 && !info.collector {
+				continue
+			}
+			addr := cfg.collectorAddr
+			if i > 0 {
+				addr = cfg.fanoutAddrs[i-1]
+			}
+			fanout[i] = countExportBytes(fanout[i], exportBytes, addr)
+		}
+	} else if info.collector {
+		traceExporter.SpanExporter = countExportBytes(exp, exportBytes, cfg.collectorAddr)
+	}
+	// The copy printed to stdout is not counted as exported to a collector.
+	var stdoutExp *stdout.Exporter
+	if cfg.alsoStdout {
+		stdoutExp, err = stdout.NewExporter(stdout.WithoutMetricExport(), stdout.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		traceExporter.SpanExporter = multiSpanExporter{traceExporter.SpanExporter, stdoutExp}
+	}
 
-	bsp := sdktrace.NewBatchSpanProcessor(exp)
+	queue := &spanQueueTracker{
+		capacity: sdktrace.DefaultMaxQueueSize,
+		warnAt:   cfg.spanQueueWarnAt,
+	}
+	// Each chunk is exported with a timeout of its own.
+	bsp := sdktrace.NewBatchSpanProcessor(queue.exporter(chunkSpans(failExports(traceExporter, cfg.failExportRate), cfg.exportChunkSize)))
+	// A sampled or dropped parent decides for its children. Only root spans
+	// consult the sampling priority, which takes precedence over the ratio,
+	// so a priority set in the middle of a trace cannot produce orphaned
+	// spans.
+	sampler := sdktrace.ParentBased(prioritySampler{
+		Sampler: sdktrace.TraceIDRatioBased(cfg.sampleRatio),
+	})
+	log.Printf("sampler: %s", sampler.Description())
+	if cfg.logSampling {
+		sampler = &loggingSampler{Sampler: sampler}
+	}
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()}),
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler:       sampler,
+			MaxAttributesPerSpan: cfg.attributeCountLimit,
+		}),
 		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
 	)
+	// Span processors are invoked in registration order, so the value
+	// length limit is applied before spans are handed to the batcher.
+	if cfg.attributeValueLengthLimit > 0 {
+		tracerProvider.RegisterSpanProcessor(truncatingSpanProcessor{limit: cfg.attributeValueLengthLimit})
+	}
+	if len(cfg.redactKeys) > 0 {
+		tracerProvider.RegisterSpanProcessor(newRedactingSpanProcessor(cfg.redactKeys))
+	}
+	if cfg.debugProbability > 0 {
+		tracerProvider.RegisterSpanProcessor(newDebugSpanProcessor())
+	}
+	tracerProvider.RegisterSpanProcessor(attributeSpanProcessor{
+		attrs: []label.KeyValue{syntheticTrafficKey.Bool(cfg.syntheticTraffic)},
+	})
+	tracerProvider.RegisterSpanProcessor(rootSpanPrinter{})
+	tracerProvider.RegisterSpanProcessor(newSpanDurationProcessor(meter))
+	inFlight := newInFlightSpanProcessor()
+	tracerProvider.RegisterSpanProcessor(inFlight)
+	tracerProvider.RegisterSpanProcessor(flushEvery(queue.processor(bsp), cfg.flushEvery))
 
-	pusher := push.New(
-		basic.New(
-			simple.NewWithExactDistribution(),
-			exp,
-		),
-		exp,
-		push.WithPeriod(7*time.Second),
-	)
+	queue.observe(meter)
+	observeUptime(meter, processStart)
+	observeSamplingRatio(meter, cfg.sampleRatio)
+	observeSpansProduced(meter)
 
-	// set global propagator to tracecontext (the default is no-op).
-	otel.SetTextMapPropagator(propagation.TraceContext{})
-	otel.SetTracerProvider(tracerProvider)
-	otel.SetMeterProvider(pusher.MeterProvider())
 	pusher.Start()
+	stopReload := reloadOnSIGHUP(cfg.configFile, cfg.pushPeriod, pushClock)
+	stopDump := dumpInFlightOnSignal(inFlight)
+	stopPing := func() {}
+	if cfg.pingInterval > 0 {
+		stopPing = startCollectorPing(
+			timeoutSpanExporter{SpanExporter: exp, timeout: cfg.traceExportTimeout},
+			conn,
+			res,
+			meter,
+			cfg.pingInterval,
+		)
+	}
+
+	shutdown := func() {
+		stopReload()
+		stopPing()
+		stopDump()
+		log.Printf("shutdown: produced %d spans", atomic.LoadInt64(&spansProduced))
+
+		// Every step runs even if an earlier one failed, and the errors are
+		// reported together once all of them are done.
+		var errs []string
+		step := func(name string, fn func() error) {
+			start := time.Now()
+			err := fn()
+			log.Printf("shutdown: %s took %s", name, time.Since(start))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+
+		// The exporter is shut down last, since stopping the pusher
+		// exports the final collection through it.
+		step("tracer provider", func() error { return tracerProvider.Shutdown(ctx) })
+		step("pusher", func() error {
+			pusher.Stop() // pushes any last exports to the receiver
+			return nil
+		})
+		if metricFile != nil {
+			step("metrics file", metricFile.Close)
+		}
+		// Shutting down the span exporter also shuts down the metric one.
+		step("exporter", func() error { return exp.Shutdown(ctx) })
+		if len(fanout) > 0 {
+			// The primary exporter has been shut down already.
+			step("fan-out exporters", func() error { return fanout[1:].Shutdown(ctx) })
+		}
+		if stdoutExp != nil {
+			step("stdout exporter", func() error { return stdoutExp.Shutdown(ctx) })
+		}
+
+		if len(errs) > 0 {
+			log.Fatalf("failed to shutdown: %s", strings.Join(errs, "; "))
+		}
+	}
 
-	return func() {
-		handleErr(tracerProvider.Shutdown(ctx), "failed to shutdown provider")
-		handleErr(exp.Shutdown(ctx), "failed to stop exporter")
-		pusher.Stop() // pushes any last exports to the receiver
+	return &providers{
+		tracerProvider: tracerProvider,
+		meterProvider:  pusher.MeterProvider(),
+		// tracecontext and baggage (the default global propagator is no-op).
+		propagator: propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		),
+		// ForceFlush of the pinned batch span processor only exports the
+		// current batch, not the spans still queued, while shutting the
+		// tracer provider down drains the queue.
+		crashFlush: func() {
+			if err := tracerProvider.Shutdown(ctx); err != nil {
+				otel.Handle(err)
+			}
+		},
+		shutdown: shutdown,
+	}, nil
+}
+
+// checkGlobalProviders fails if the global providers are not tp and mp, for
+// instance because something else replaced them, which would leave the
+// example recording to providers that are never exported.
+func checkGlobalProviders(tp trace.TracerProvider, mp metric.MeterProvider) error {
+	if got := otel.GetTracerProvider(); got != tp {
+		return fmt.Errorf("global tracer provider is %T, not the configured one", got)
+	}
+	if got := otel.GetMeterProvider(); got != mp {
+		return fmt.Errorf("global meter provider is %T, not the configured one", got)
 	}
+	return nil
 }
 
 func handleErr(err error, message string) {
@@ -97,12 +361,76 @@ func handleErr(err error, message string) {
 	}
 }
 
+// countingErrorHandler logs the errors reported by the SDK, such as failed
+// exports, and keeps count of them.
+//
+// Spans and points a collector accepts only partially are not reported: the
+// OTLP protocol of the pinned exporter has no partial success in its export
+// responses, and the exporter discards the responses anyway.
+type countingErrorHandler struct {
+	n int64
+}
+
+func (h *countingErrorHandler) Handle(err error) {
+	// The tracer provider hands every span processor's shutdown result to
+	// the handler, including nil ones.
+	if err == nil {
+		return
+	}
+	atomic.AddInt64(&h.n, 1)
+	log.Print(err)
+}
+
+func (h *countingErrorHandler) count() int64 {
+	return atomic.LoadInt64(&h.n)
+}
+
 func main() {
-	shutdown := initProvider()
+	cfg := parseFlags()
+	if cfg.listExporters {
+		listExporters(os.Stdout)
+		return
+	}
+
+	errs := &countingErrorHandler{}
+	otel.SetErrorHandler(errs)
+
+	conn := newConnStateTracker()
+	loop := &pauseSwitch{}
+	if cfg.adminAddr != "" {
+		serveAdmin(cfg.adminAddr, conn, loop)
+	}
+
+	if cfg.runFakeCollector {
+		collector, err := startFakeCollector(cfg.collectorAddr)
+		handleErr(err, "failed to start fake collector")
+		defer collector.stop()
+	}
+
+	if cfg.smokeTest {
+		p, err := initProviderWithin(cfg, conn, cfg.smokeTestTimeout)
+		handleErr(err, "smoke test failed")
+		if !runSmokeTest(cfg, errs, conn, p.shutdown) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	p, err := initProvider(cfg, conn)
+	handleErr(err, "failed to initialize providers")
+	shutdown := p.shutdown
 	defer shutdown()
+	// From here on a SIGINT or SIGTERM cancels ctx rather than killing the
+	// process, so that whatever runs ends early and the telemetry recorded
+	// so far is flushed.
+	ctx := cancelOnTermination(context.Background())
 
-	tracer := otel.Tracer("test-tracer")
-	// meter := otel.Meter("test-meter")
+	version := buildVersion()
+	tracer := otel.GetTracerProvider().Tracer(cfg.tracerName, trace.WithInstrumentationVersion(version))
+	// Instruments are split across two meters, whose instrumentation names
+	// tell the request metrics and the line metrics apart in backends.
+	meter := otel.Meter(cfg.meterName, metric.WithInstrumentationVersion(version))
+	lineMeter := otel.Meter(cfg.lineMeterName, metric.WithInstrumentationVersion(version))
 
 	// labels represent additional key-value descriptors that can be bound to a
 	// metric observer or recorder.
@@ -112,27 +440,237 @@ func main() {
 		label.String("client", "cli"),
 	}
 
+	sim, unbind := newSimulation(cfg, tracer, meter, lineMeter, commonLabels)
+	defer unbind()
+	sim.crashFlush = p.crashFlush
+
+	defaultCtx := baggage.ContextWithValues(ctx, commonLabels...)
+	if cfg.samplingPriority > 0 {
+		defaultCtx = baggage.ContextWithValues(defaultCtx, samplingPriorityKey.Int(cfg.samplingPriority))
+	}
+	if cfg.debugGoroutines {
+		defaultCtx = withGoroutineID(defaultCtx, newGoroutineID())
+	}
+	if cfg.spanLimitsDemo {
+		spanLimitsDemo(defaultCtx, tracer, cfg)
+	}
+
+	if cfg.traceStateKey != "" {
+		member, err := traceStateMember(cfg.traceStateKey, cfg.traceStateValue)
+		handleErr(err, "invalid tracestate")
+		defaultCtx = contextWithTraceState(defaultCtx, member)
+		traceStateDemo(defaultCtx, tracer, member)
+	}
+
+	if cfg.verifyPropagation {
+		if !verifyPropagation(defaultCtx, tracer) {
+			shutdown()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.dumpHeaders {
+		dumpHeaders(defaultCtx, tracer)
+		return
+	}
+
+	if cfg.chainDemo {
+		err := runChain(defaultCtx)
+		if defaultCtx.Err() != nil {
+			// The requests fail once canceled, which is not the demo's
+			// fault, and the deferred shutdown flushes what was recorded.
+			log.Print("chain demo canceled")
+			return
+		}
+		handleErr(err, "chain demo failed")
+		return
+	}
+
+	if cfg.clockSkew > 0 {
+		skewDemo(defaultCtx, tracer, cfg.clockSkew)
+		return
+	}
+
+	if cfg.injectErrorTrace || cfg.errorTraceOnly {
+		if injectErrorTrace(defaultCtx, tracer) {
+			log.Println("recorded the canonical error trace")
+		} else {
+			log.Println("warning: the canonical error trace was not sampled, check the configured sampler")
+		}
+		if cfg.errorTraceOnly {
+			return
+		}
+	}
+
+	if cfg.stress != "" {
+		handleErr(runStress(defaultCtx, tracer, cfg), "stress failed")
+		return
+	}
+
+	if cfg.workers > 0 {
+		sim.pool = newWorkerPool(cfg.workers, func(ctx context.Context, rng *rand.Rand) {
+			defer sim.flushOnPanic()
+			sim.f2(ctx, rng)
+		})
+		defer sim.pool.close()
+	}
+
+	if cfg.startupDelay > 0 {
+		log.Printf("waiting %s before starting the run", cfg.startupDelay)
+		select {
+		case <-time.After(cfg.startupDelay):
+		case <-defaultCtx.Done():
+			log.Print("canceled before the run started")
+			return
+		}
+	}
+
+	// The run ends after the configured number of iterations or duration,
+	// whichever comes first, or when the process is asked to terminate, and
+	// the deferred shutdown then flushes the telemetry recorded so far. A
+	// request in progress when the run ends is completed first.
+	runCtx := defaultCtx
+	if cfg.duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, cfg.duration)
+		defer cancel()
+	}
+	// With --stdin, each iteration waits for a line of input, and the run
+	// also ends with the input.
+	r := &run{
+		iterations:    cfg.iterations,
+		duration:      cfg.duration,
+		tagIterations: cfg.tagIterations,
+		pause:         loop,
+	}
+	if cfg.traceparent != "" {
+		parent, err := extractTraceparent(cfg.traceparent)
+		handleErr(err, "failed to continue the trace")
+		log.Printf("continuing trace_id=%s from parent span_id=%s", parent.TraceID, parent.SpanID)
+		r.parent = parent
+	}
+	if cfg.stdin {
+		r.input = bufio.NewScanner(os.Stdin)
+	}
+	sim.drive(runCtx, r, cfg.concurrency)
+}
+
+// simulationClock returns the clock of the simulated requests: the wall
+// clock, or with synthetic timestamps a fake clock starting now. The fake
+// clock does not sleep, so requests run as fast as they can be recorded
+// and their timestamps soon run ahead of the wall clock. It is shared by
+// all requests, which start in the order they advance it to.
+func simulationClock(cfg config) clock {
+	if cfg.syntheticTimestamps {
+		return newFakeClock(time.Now())
+	}
+	return realClock{}
+}
+
+// latencyBuckets are the exclusive upper bounds, in milliseconds, of the
+// simulated request latencies.
+var latencyBuckets = []int64{17001, 8007, 917, 87, 1173}
+
+// latencyBucket picks the latency bucket for a request started at now. The
+// bucket changes every second, cycling through all of them.
+func latencyBucket(now time.Time) int {
+	return int(now.Unix() % int64(len(latencyBuckets)))
+}
+
+// simulateLatency returns a random request latency within bucket.
+func simulateLatency(rng *rand.Rand, bucket int) time.Duration {
+	return time.Duration(rng.Int63n(latencyBuckets[bucket])) * time.Millisecond
+}
+
+// spanLimitsDemo records a single span that exceeds the configured span
+// limits: the oldest attributes past the count limit are dropped and the
+// oversized value is truncated before export.
+func spanLimitsDemo(ctx context.Context, tracer trace.Tracer, cfg config) {
+	_, span := tracer.Start(ctx, "SpanLimitsDemo")
+	defer span.End()
+
+	for i := 0; i < cfg.attributeCountLimit; i++ {
+		span.SetAttributes(label.Int(fmt.Sprintf("demo.attribute.%d", i), i))
+	}
+	span.SetAttributes(label.String("demo.oversized", strings.Repeat("x", cfg.attributeValueLengthLimit+1)))
+}
+
+// maxLineLengthKey is the span attribute holding the range of the longest
+// line of a request.
+const maxLineLengthKey = label.Key("appdemo.max_line_length")
+
+// inputLineKey is the span attribute holding the line of input a request
+// was run for with --stdin.
+const inputLineKey = label.Key("input.line")
+
+// loopIterationKey is the span attribute holding the sequence number of a
+// request within the run, starting at 1, set with --tag-iterations.
+const loopIterationKey = label.Key("loop.iteration")
+
+// tenantIDKey is the span attribute holding the simulated tenant of a
+// request.
+const tenantIDKey = label.Key("tenant.id")
+
+// simulation holds what the simulated requests need besides their context
+// and random number generator.
+type simulation struct {
+	cfg            config
+	clock          clock
+	tracer         trace.Tracer
+	requestLatency float64Recorder
+	requestCount   int64Adder
+	linesTotal     int64Adder
+	payloadSize    int64Recorder
+	// childSpans records the number of child spans of each top-level
+	// request.
+	childSpans int64Recorder
+	// requestsByBucket holds a counter per latency bucket.
+	requestsByBucket []int64Adder
+
+	// pool runs the nested work of f1 when not nil.
+	pool *workerPool
+	// crashFlush exports the spans ended so far when the program is about
+	// to crash.
+	crashFlush func()
+}
+
+// newSimulation returns the simulation of cfg recording spans with tracer,
+// the request metrics with meter and the line metrics with lineMeter, all of
+// them with labels. The returned function releases the bound instruments.
+func newSimulation(cfg config, tracer trace.Tracer, meter, lineMeter metric.Meter, labels []label.KeyValue) (*simulation, func()) {
+	instruments := newInstrumentCache(meter)
+	lineInstruments := newInstrumentCache(lineMeter)
+	var unbinds []func()
+
 	// Recorder metric example
-	// requestLatency := metric.Must(meter).
-	// 	NewFloat64ValueRecorder(
-	// 		"appdemo/request_latency",
-	// 		metric.WithDescription("The latency of requests processed"),
-	// 	).Bind(commonLabels...)
-	// defer requestLatency.Unbind()
+	requestLatency, unbind := withFloat64ValueRecorderLabels(
+		instruments.
+			NewFloat64ValueRecorder(
+				requestLatencyName,
+				metric.WithDescription("The latency of requests processed"),
+				metric.WithUnit(unit.Unit(cfg.latencyUnit)),
+			),
+		cfg.boundInstruments, labels...)
+	unbinds = append(unbinds, unbind)
 
 	// TODO: Use a view to just count number of measurements for requestLatency when available.
-	// requestCount := metric.Must(meter).
-	// 	NewInt64Counter(
-	// 		"appdemo/request_counts",
-	// 		metric.WithDescription("The number of requests processed"),
-	// 	).Bind(commonLabels...)
-	// defer requestCount.Unbind()
+	requestCount, unbind := withInt64CounterLabels(
+		instruments.
+			NewInt64Counter(
+				"appdemo/request_counts",
+				metric.WithDescription("The number of requests processed"),
+				metric.WithUnit(unit.Dimensionless),
+			),
+		cfg.boundInstruments, labels...)
+	unbinds = append(unbinds, unbind)
 
 	// lineLengths := metric.Must(meter).
 	// 	NewInt64ValueRecorder(
 	// 		"appdemo/line_lengths",
 	// 		metric.WithDescription("The lengths of the various lines in"),
-	// 	).Bind(commonLabels...)
+	// 		metric.WithUnit(unit.Bytes),
+	// 	).Bind(labels...)
 	// defer lineLengths.Unbind()
 
 	// TODO: Use a view to just count number of measurements for lineLengths when available.
@@ -140,84 +678,261 @@ func main() {
 	// 	NewInt64Counter(
 	// 		"appdemo/line_counts",
 	// 		metric.WithDescription("The counts of the lines in"),
-	// 	).Bind(commonLabels...)
+	// 		metric.WithUnit(unit.Dimensionless),
+	// 	).Bind(labels...)
 	// defer lineCounts.Unbind()
 
-	defaultCtx := baggage.ContextWithValues(context.Background(), commonLabels...)
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for {
-		f1(defaultCtx, rng, tracer)
+	// Unlike lineCounts, linesTotal is added to once per request with the
+	// number of lines the request generated.
+	linesTotal, unbind := withInt64CounterLabels(
+		lineInstruments.
+			NewInt64Counter(
+				"appdemo/lines_total",
+				metric.WithDescription("The total number of lines generated by all requests"),
+				metric.WithUnit(unit.Dimensionless),
+			),
+		cfg.boundInstruments, labels...)
+	unbinds = append(unbinds, unbind)
+
+	// payloadSize records the size of every line, unlike linesTotal which
+	// only counts them.
+	payloadSize, unbind := withInt64ValueRecorderLabels(
+		lineInstruments.
+			NewInt64ValueRecorder(
+				payloadSizeName,
+				metric.WithDescription("The sizes of the lines generated by requests"),
+				metric.WithUnit(unit.Bytes),
+			),
+		cfg.boundInstruments, labels...)
+	unbinds = append(unbinds, unbind)
+
+	childSpans, unbind := withInt64ValueRecorderLabels(
+		instruments.
+			NewInt64ValueRecorder(
+				"appdemo/child_spans_per_request",
+				metric.WithDescription("The number of child spans each top-level request produced, constant at 1 for now since every request makes a single nested call"),
+				metric.WithUnit(unit.Dimensionless),
+			),
+		cfg.boundInstruments, labels...)
+	unbinds = append(unbinds, unbind)
+
+	// requestsByBucket is recorded with one label set per latency bucket,
+	// which keeps its cardinality at the number of buckets.
+	requestsByBucket := instruments.
+		NewInt64Counter(
+			"appdemo/requests_by_bucket",
+			metric.WithDescription("The number of requests processed per simulated latency bucket"),
+			metric.WithUnit(unit.Dimensionless),
+		)
+	bucketCounters := make([]int64Adder, len(latencyBuckets))
+	for i := range latencyBuckets {
+		bucketLabels := append(append([]label.KeyValue(nil), labels...), label.Int("bucket", i))
+		bucketCounters[i], unbind = withInt64CounterLabels(requestsByBucket, cfg.boundInstruments, bucketLabels...)
+		unbinds = append(unbinds, unbind)
+	}
+
+	s := &simulation{
+		cfg:              cfg,
+		clock:            simulationClock(cfg),
+		tracer:           tracer,
+		requestLatency:   requestLatency,
+		requestCount:     requestCount,
+		linesTotal:       linesTotal,
+		payloadSize:      payloadSize,
+		childSpans:       childSpans,
+		requestsByBucket: bucketCounters,
+		crashFlush:       func() {},
+	}
+	return s, func() {
+		for _, unbind := range unbinds {
+			unbind()
+		}
 	}
 }
 
-func f1(ctx context.Context, rng *rand.Rand, tracer trace.Tracer) {
-	startTime := time.Now()
-	// ctx, span := tracer.Start(defaultCtx, "ExecuteRequest")
-	childCtx, span := tracer.Start(ctx, "ExecuteRequest")
-	var sleep int64
-	switch modulus := time.Now().Unix() % 5; modulus {
-	case 0:
-		sleep = rng.Int63n(17001)
-	case 1:
-		sleep = rng.Int63n(8007)
-	case 2:
-		sleep = rng.Int63n(917)
-	case 3:
-		sleep = rng.Int63n(87)
-	case 4:
-		sleep = rng.Int63n(1173)
-	}
-
-	time.Sleep(time.Duration(sleep) * time.Millisecond)
-
-	span.End()
-	latencyMs := float64(time.Since(startTime)) / 1e6
-	nr := int(rng.Int31n(7))
-	for i := 0; i < nr; i++ {
-		randLineLength := rng.Int63n(999)
-		// lineLengths.Record(ctx, randLineLength)
-		// lineCounts.Add(ctx, 1)
-		fmt.Printf("#%d: LineLength: %dBy\n", i, randLineLength)
+// recordLatency records the latency of the request whose span is active in
+// ctx. Recording with the span's context is what lets a metric SDK sample
+// the measurement as an exemplar linked to the trace. Exemplars are only
+// supported by later releases of the Go metric SDK; the pinned v0.14.0 one
+// ignores the span, but nothing here needs to change once it is upgraded.
+//
+// With sampledLatencyOnly set, only the latencies of sampled requests are
+// recorded, which cuts the metric volume along with the trace volume. The
+// latency statistics then describe the sampled requests only, and are
+// biased wherever sampling is, for example by the sampling priority.
+func (s *simulation) recordLatency(ctx context.Context, latency time.Duration) {
+	if s.cfg.sampledLatencyOnly && !trace.SpanFromContext(ctx).SpanContext().IsSampled() {
+		return
 	}
+	s.requestLatency.Record(ctx, float64(latency)/float64(latencyUnits[s.cfg.latencyUnit]))
+}
 
-	f2(childCtx, rng, tracer)
+// f1 executes a request with the additional span attributes attrs and then
+// its nested work, either inline or, when pool is not nil, on one of the
+// pool's workers.
+func (s *simulation) f1(ctx context.Context, rng *rand.Rand, attrs ...label.KeyValue) {
+	if s.cfg.debugProbability > 0 {
+		ctx = withRandomDebug(ctx, rng, s.cfg.debugProbability)
+	}
+	if len(s.cfg.tenants) > 0 {
+		attrs = append(attrs, tenantIDKey.String(s.cfg.tenants[rng.Intn(len(s.cfg.tenants))]))
+	}
+	// Each request makes a single nested call producing a single child
+	// span, so the count is always 1 for now. On the pool, the child may
+	// still be in progress when the count is recorded.
+	var children int64
+	s.work(ctx, rng, attrs, func(childCtx context.Context) {
+		children++
+		if s.pool != nil {
+			s.pool.submit(childCtx)
+		} else {
+			s.f2(childCtx, rng)
+		}
+	})
+	s.childSpans.Record(ctx, children)
+}
 
-	// requestLatency.Record(ctx, latencyMs)
-	// requestCount.Add(ctx, 1)
-	fmt.Printf("Latency: %.3fms\n", latencyMs)
+// f2 executes the nested work of a request.
+func (s *simulation) f2(ctx context.Context, rng *rand.Rand) {
+	s.work(ctx, rng, nil, nil)
 }
 
-func f2(ctx context.Context, rng *rand.Rand, tracer trace.Tracer) {
-	startTime := time.Now()
+// work executes a single request with a span of its own, started with attrs
+// in addition to the common attributes. If nested is not nil, it is called
+// with the context of the span once the span has ended.
+func (s *simulation) work(ctx context.Context, rng *rand.Rand, attrs []label.KeyValue, nested func(context.Context)) {
+	startTime := s.clock.Now()
 	// ctx, span := tracer.Start(defaultCtx, "ExecuteRequest")
-	_, span := tracer.Start(ctx, "ExecuteRequest")
-	var sleep int64
-	switch modulus := time.Now().Unix() % 5; modulus {
-	case 0:
-		sleep = rng.Int63n(17001)
-	case 1:
-		sleep = rng.Int63n(8007)
-	case 2:
-		sleep = rng.Int63n(917)
-	case 3:
-		sleep = rng.Int63n(87)
-	case 4:
-		sleep = rng.Int63n(1173)
-	}
-
-	time.Sleep(time.Duration(sleep) * time.Millisecond)
-
-	span.End()
-	latencyMs := float64(time.Since(startTime)) / 1e6
+	attrs = append(append(samplingPriority(ctx), goroutineID(ctx)...), attrs...)
+	attrs = append(attrs, s.cfg.spanAttributes...)
+	attrs = append(attrs, randomAttributes(rng, s.cfg.randomAttributes)...)
+	if values := s.cfg.attrValues; len(values) > 0 {
+		attrs = append(attrs, label.String(s.cfg.attrValuesKey, values[rng.Intn(len(values))]))
+	}
+	// The span is timed by s.clock, so that a fake clock decides its
+	// duration as well.
+	spanCtx, span := s.tracer.Start(ctx, s.spanName(rng), trace.WithAttributes(attrs...), trace.WithTimestamp(startTime))
+	atomic.AddInt64(&spansProduced, 1)
+	defer func() {
+		// A panic is recorded on every span it unwinds through that has
+		// not ended yet; ending a span twice has no effect.
+		if r := recover(); r != nil {
+			span.RecordError(fmt.Errorf("panic: %v", r))
+			span.SetStatus(codes.Error, "panic")
+			span.End()
+			panic(r)
+		}
+	}()
+	bucket := latencyBucket(s.clock.Now())
+	s.requestsByBucket[bucket].Add(ctx, 1)
+	latency := simulateLatency(rng, bucket)
+	// The events mark the simulated work within the span, timed like the
+	// span by s.clock: it starts with the span and takes latency.
+	span.AddEvent("work-started", trace.WithTimestamp(startTime), trace.WithAttributes(label.Int("bucket", bucket)))
+	s.clock.Sleep(latency)
+	span.AddEvent("work-completed", trace.WithTimestamp(startTime.Add(latency)), trace.WithAttributes(
+		label.Int64("latency_ms", latency.Milliseconds()),
+	))
+	if s.cfg.slowThreshold > 0 && latency > s.cfg.slowThreshold {
+		addSlowEvent(span, latency, bucket)
+	}
+
+	elapsed := s.since(startTime, latency)
+	latencyMs := float64(elapsed) / 1e6
 	nr := int(rng.Int31n(7))
+	var maxLineLength int64
 	for i := 0; i < nr; i++ {
 		randLineLength := rng.Int63n(999)
 		// lineLengths.Record(ctx, randLineLength)
 		// lineCounts.Add(ctx, 1)
-		fmt.Printf("#%d: LineLength: %dBy\n", i, randLineLength)
+		s.payloadSize.Record(ctx, randLineLength)
+		if s.cfg.printSampleRate >= 1 || rng.Float64() < s.cfg.printSampleRate {
+			fmt.Printf("#%d: LineLength: %dBy\n", i, randLineLength)
+		}
+		if randLineLength > maxLineLength {
+			maxLineLength = randLineLength
+		}
+	}
+	span.SetAttributes(maxLineLengthKey.String(s.cfg.lineLengthBuckets.bucket(maxLineLength)))
+	span.End(trace.WithTimestamp(startTime.Add(s.since(startTime, latency))))
+	s.linesTotal.Add(ctx, int64(nr))
+
+	if nested != nil {
+		nested(spanCtx)
 	}
 
-	// requestLatency.Record(ctx, latencyMs)
-	// requestCount.Add(ctx, 1)
+	s.recordLatency(spanCtx, elapsed)
+	s.requestCount.Add(ctx, 1)
 	fmt.Printf("Latency: %.3fms\n", latencyMs)
+	if s.cfg.enableLogs {
+		logRequest(span, latencyMs, nr)
+	}
+}
+
+// since returns the time a request started at start and sleeping for
+// latency has taken so far. The fake clock of synthetic timestamps is
+// shared by every goroutine and advanced by all of their sleeps, so a
+// request on it has only taken its own latency.
+func (s *simulation) since(start time.Time, latency time.Duration) time.Duration {
+	if s.cfg.syntheticTimestamps {
+		return latency
+	}
+	return s.clock.Now().Sub(start)
+}
+
+// maxRandomAttributes caps the number of random attributes per span.
+const maxRandomAttributes = 128
+
+// flushOnPanic exports the spans ended so far, including those ended by the
+// panic, when the goroutine it is deferred on panics, and then carries on
+// panicking. Spans still queued would be lost when the program crashes.
+func (s *simulation) flushOnPanic() {
+	if r := recover(); r != nil {
+		s.crashFlush()
+		panic(r)
+	}
+}
+
+// randomAttributes returns n attributes with random keys and values, each
+// key and value likely never to be seen again, to stress the attribute and
+// cardinality handling of backends.
+func randomAttributes(rng *rand.Rand, n int) []label.KeyValue {
+	attrs := make([]label.KeyValue, n)
+	for i := range attrs {
+		attrs[i] = label.Int64(fmt.Sprintf("random.%08x", rng.Uint32()), rng.Int63())
+	}
+	return attrs
+}
+
+// addSlowEvent records on span that the request took the slow path, with
+// its simulated latency d and the latency bucket it was drawn from.
+func addSlowEvent(span trace.Span, d time.Duration, bucket int) {
+	span.AddEvent("SlowPath", trace.WithAttributes(
+		label.Int64("latency_ms", d.Milliseconds()),
+		label.Int("bucket", bucket),
+	))
+}
+
+// defaultSpanNames are the span names picked from when span names are
+// randomized without a list of names of their own.
+var defaultSpanNames = []string{
+	"ExecuteRequest",
+	"FetchUser",
+	"QueryDatabase",
+	"RenderPage",
+	"CallDownstream",
+}
+
+// spanName returns the name of the span of a request: ExecuteRequest, or a
+// random one of the configured names if span names are randomized. The
+// configured names are normalized, since they may be paths holding IDs.
+func (s *simulation) spanName(rng *rand.Rand) string {
+	if !s.cfg.randomSpanNames {
+		return "ExecuteRequest"
+	}
+	names := []string(s.cfg.spanNames)
+	if len(names) == 0 {
+		names = defaultSpanNames
+	}
+	return normalizeSpanName(names[rng.Intn(len(names))], s.cfg.spanNameRules.rules)
 }
@@ -0,0 +1,233 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testConfig returns the configuration parseFlags returns for the command
+// line arguments args.
+func testConfig(t *testing.T, args ...string) config {
+	t.Helper()
+	defer func(fs *flag.FlagSet, osArgs []string) {
+		flag.CommandLine, os.Args = fs, osArgs
+	}(flag.CommandLine, os.Args)
+	flag.CommandLine = flag.NewFlagSet(t.Name(), flag.PanicOnError)
+	os.Args = append([]string{t.Name()}, args...)
+	return parseFlags()
+}
+
+// startTestCollector starts a fake collector on an in-process listener and
+// points cfg at it. The collector is stopped when the test ends.
+func startTestCollector(t *testing.T, cfg *config) *fakeCollector {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	c := serveFakeCollector(lis)
+	t.Cleanup(c.server.Stop)
+	cfg.collectorAddr = "bufconn"
+	cfg.dialer = func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+	return c
+}
+
+// newTestSimulation returns the simulation of cfg recording spans with
+// tracer and metrics with meter, with a worker pool if cfg asks for one,
+// which the caller closes.
+func newTestSimulation(t *testing.T, cfg config, tracer trace.Tracer, meter metric.Meter) *simulation {
+	t.Helper()
+	s, unbind := newSimulation(cfg, tracer, meter, meter, nil)
+	t.Cleanup(unbind)
+	if cfg.workers > 0 {
+		s.pool = newWorkerPool(cfg.workers, func(ctx context.Context, rng *rand.Rand) {
+			s.f2(ctx, rng)
+		})
+	}
+	return s
+}
+
+func TestProvidersExportToCollector(t *testing.T) {
+	const iterations = 5
+	cfg := testConfig(t, "--synthetic-timestamps", "--print-sample-rate=0")
+	collector := startTestCollector(t, &cfg)
+
+	p, err := newProviders(cfg, newConnStateTracker())
+	if err != nil {
+		t.Fatalf("newProviders: %v", err)
+	}
+	sim := newTestSimulation(t, cfg, p.tracerProvider.Tracer(cfg.tracerName), p.meterProvider.Meter(cfg.meterName))
+	sim.crashFlush = p.crashFlush
+	sim.drive(context.Background(), &run{iterations: iterations}, 1)
+	p.shutdown()
+
+	// Every iteration records a request span and the span of its nested
+	// work.
+	if got, want := atomic.LoadInt64(&collector.spans), int64(2*iterations); got != want {
+		t.Errorf("collector received %d spans, want %d", got, want)
+	}
+}
+
+func TestNewProvidersLeavesGlobalsAlone(t *testing.T) {
+	cfg := testConfig(t)
+	startTestCollector(t, &cfg)
+	tp, mp, prop := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+
+	p, err := newProviders(cfg, newConnStateTracker())
+	if err != nil {
+		t.Fatalf("newProviders: %v", err)
+	}
+	defer p.shutdown()
+
+	if otel.GetTracerProvider() != tp {
+		t.Error("newProviders replaced the global tracer provider")
+	}
+	if otel.GetMeterProvider() != mp {
+		t.Error("newProviders replaced the global meter provider")
+	}
+	if otel.GetTextMapPropagator() != prop {
+		t.Error("newProviders replaced the global propagator")
+	}
+}
+
+func TestStdoutExporterIgnoresCollectorFlags(t *testing.T) {
+	cfg := testConfig(t, "--exporter=stdout", "--also-stdout", "--collector-ping-interval=1s", "--temporality=delta")
+	if cfg.alsoStdout {
+		t.Error("--also-stdout kept with --exporter=stdout, every span would be printed twice")
+	}
+	if cfg.pingInterval != 0 {
+		t.Errorf("--collector-ping-interval = %s with --exporter=stdout, want 0", cfg.pingInterval)
+	}
+
+	cfg = testConfig(t, "--also-stdout", "--collector-ping-interval=1s")
+	if !cfg.alsoStdout || cfg.pingInterval != time.Second {
+		t.Error("collector flags changed with --exporter=otlp")
+	}
+}
+
+func TestSimulateLatency(t *testing.T) {
+	tests := []struct {
+		bucket int
+		max    time.Duration
+	}{
+		{0, 17001 * time.Millisecond},
+		{1, 8007 * time.Millisecond},
+		{2, 917 * time.Millisecond},
+		{3, 87 * time.Millisecond},
+		{4, 1173 * time.Millisecond},
+	}
+	if len(tests) != len(latencyBuckets) {
+		t.Fatalf("%d buckets tested, want %d", len(tests), len(latencyBuckets))
+	}
+	for _, tt := range tests {
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 1000; i++ {
+			d := simulateLatency(rng, tt.bucket)
+			if d < 0 || d >= tt.max {
+				t.Fatalf("simulateLatency(bucket %d) = %s, want within [0, %s)", tt.bucket, d, tt.max)
+			}
+			if d%time.Millisecond != 0 {
+				t.Fatalf("simulateLatency(bucket %d) = %s, want whole milliseconds", tt.bucket, d)
+			}
+		}
+	}
+}
+
+func TestLatencyBucket(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want int
+	}{
+		{0, 0},
+		{1, 1},
+		{4, 4},
+		{5, 0},
+		{1602668400, 0},
+		{1602668403, 3},
+	}
+	for _, tt := range tests {
+		if got := latencyBucket(time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("latencyBucket(time.Unix(%d, 0)) = %d, want %d", tt.unix, got, tt.want)
+		}
+	}
+	// The bucket only changes with the second.
+	if got := latencyBucket(time.Unix(3, 999999999)); got != 3 {
+		t.Errorf("latencyBucket(time.Unix(3, 999999999)) = %d, want 3", got)
+	}
+}
+
+// newRecordingTracer returns a tracer sampling every span and exporting it
+// to exp as soon as it ends.
+func newRecordingTracer(exp *recordingExporter) trace.Tracer {
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()}),
+		sdktrace.WithSyncer(exp),
+	)
+	return tp.Tracer("test")
+}
+
+// yieldingClock lets other goroutines run after every sleep.
+type yieldingClock struct {
+	clock
+}
+
+func (c yieldingClock) Sleep(d time.Duration) {
+	c.clock.Sleep(d)
+	runtime.Gosched()
+}
+
+func TestSyntheticSpanDurations(t *testing.T) {
+	cfg := testConfig(t, "--synthetic-timestamps", "--print-sample-rate=0")
+	exp := &recordingExporter{}
+	sim := newTestSimulation(t, cfg, newRecordingTracer(exp), metric.NoopMeterProvider{}.Meter("test"))
+	// Concurrent requests advance the shared fake clock while others are
+	// in progress, which yielding after every sleep makes sure of.
+	sim.clock = yieldingClock{sim.clock}
+	sim.drive(context.Background(), &run{iterations: 50}, 8)
+
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+	if len(exp.spans) != 100 {
+		t.Fatalf("%d spans exported, want 100", len(exp.spans))
+	}
+	for _, sd := range exp.spans {
+		var latency time.Duration = -1
+		for _, e := range sd.MessageEvents {
+			if e.Name == "work-completed" {
+				latency = time.Duration(attributeMap(e.Attributes)["latency_ms"].AsInt64()) * time.Millisecond
+			}
+		}
+		if latency < 0 {
+			t.Fatalf("span %s has no work-completed event", sd.SpanContext.SpanID)
+		}
+		if got := sd.EndTime.Sub(sd.StartTime); got != latency {
+			t.Errorf("span %s took %s, want its simulated latency %s", sd.SpanContext.SpanID, got, latency)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric/number"
+	metricexport "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+)
+
+// metricFileExporter appends every export to a file, as a line of JSON,
+// before handing it to the wrapped exporter. Exports happen once per push
+// period, and the last one when the pusher stops. The pinned OTLP exporter
+// keeps its protobuf types internal, so the JSON follows the layout and
+// field names of OTLP's JSON encoding rather than being produced by it.
+type metricFileExporter struct {
+	metricexport.Exporter
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// newMetricFileExporter returns exp appending its exports to the file at
+// path, which is created if it does not exist.
+func newMetricFileExporter(exp metricexport.Exporter, path string) (*metricFileExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &metricFileExporter{Exporter: exp, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Export writes cs to the file and then exports it with the wrapped
+// exporter. Failing to write the file does not fail the export; the error
+// goes to the global error handler.
+func (e *metricFileExporter) Export(ctx context.Context, cs metricexport.CheckpointSet) error {
+	if err := e.write(cs); err != nil {
+		otel.Handle(fmt.Errorf("failed to write metrics to %s: %w", e.f.Name(), err))
+	}
+	return e.Exporter.Export(ctx, cs)
+}
+
+// Close closes the file once the last export has been written.
+func (e *metricFileExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.f.Close()
+}
+
+// The JSON layout of an export, following OTLP's ExportMetricsServiceRequest.
+type (
+	jsonExport struct {
+		ResourceMetrics []jsonResourceMetrics `json:"resourceMetrics"`
+	}
+	jsonResourceMetrics struct {
+		Resource                      jsonResource                         `json:"resource"`
+		InstrumentationLibraryMetrics []*jsonInstrumentationLibraryMetrics `json:"instrumentationLibraryMetrics"`
+	}
+	jsonResource struct {
+		Attributes []jsonKeyValue `json:"attributes"`
+	}
+	jsonInstrumentationLibraryMetrics struct {
+		InstrumentationLibrary jsonInstrumentationLibrary `json:"instrumentationLibrary"`
+		Metrics                []*jsonMetric              `json:"metrics"`
+	}
+	jsonInstrumentationLibrary struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	jsonMetric struct {
+		Name                   string          `json:"name"`
+		Description            string          `json:"description,omitempty"`
+		Unit                   string          `json:"unit,omitempty"`
+		InstrumentKind         string          `json:"instrumentKind"`
+		AggregationTemporality string          `json:"aggregationTemporality"`
+		DataPoints             []jsonDataPoint `json:"dataPoints"`
+	}
+	jsonDataPoint struct {
+		Labels            []jsonKeyValue `json:"labels,omitempty"`
+		StartTimeUnixNano int64          `json:"startTimeUnixNano"`
+		TimeUnixNano      int64          `json:"timeUnixNano"`
+		Value             interface{}    `json:"value,omitempty"`
+		Sum               interface{}    `json:"sum,omitempty"`
+		Count             *int64         `json:"count,omitempty"`
+		Min               interface{}    `json:"min,omitempty"`
+		Max               interface{}    `json:"max,omitempty"`
+		BucketCounts      []float64      `json:"bucketCounts,omitempty"`
+		ExplicitBounds    []float64      `json:"explicitBounds,omitempty"`
+	}
+	jsonKeyValue struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}
+)
+
+// write appends cs to the file as a single line.
+func (e *metricFileExporter) write(cs metricexport.CheckpointSet) error {
+	var rm jsonResourceMetrics
+	libraries := make(map[jsonInstrumentationLibrary]*jsonInstrumentationLibraryMetrics)
+	metrics := make(map[*jsonInstrumentationLibraryMetrics]map[string]*jsonMetric)
+
+	// The records are iterated with the wrapped exporter's temporality, as
+	// the processor computes them for it.
+	err := cs.ForEach(e.Exporter, func(r metricexport.Record) error {
+		desc := r.Descriptor()
+		if rm.Resource.Attributes == nil {
+			rm.Resource.Attributes = jsonKeyValues(r.Resource().Attributes())
+		}
+
+		lib := jsonInstrumentationLibrary{Name: desc.InstrumentationName(), Version: desc.InstrumentationVersion()}
+		ilm, ok := libraries[lib]
+		if !ok {
+			ilm = &jsonInstrumentationLibraryMetrics{InstrumentationLibrary: lib}
+			libraries[lib] = ilm
+			metrics[ilm] = make(map[string]*jsonMetric)
+			rm.InstrumentationLibraryMetrics = append(rm.InstrumentationLibraryMetrics, ilm)
+		}
+		m, ok := metrics[ilm][desc.Name()]
+		if !ok {
+			agg := r.Aggregation()
+			m = &jsonMetric{
+				Name:                   desc.Name(),
+				Description:            desc.Description(),
+				Unit:                   string(desc.Unit()),
+				InstrumentKind:         desc.InstrumentKind().String(),
+				AggregationTemporality: e.ExportKindFor(desc, agg.Kind()).String(),
+			}
+			metrics[ilm][desc.Name()] = m
+			ilm.Metrics = append(ilm.Metrics, m)
+		}
+
+		dp, err := jsonDataPointOf(r, desc.NumberKind())
+		if err != nil {
+			return err
+		}
+		m.DataPoints = append(m.DataPoints, dp)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(jsonExport{ResourceMetrics: []jsonResourceMetrics{rm}})
+}
+
+// jsonDataPointOf returns the data point of r, with the fields of its
+// aggregation set.
+func jsonDataPointOf(r metricexport.Record, kind number.Kind) (jsonDataPoint, error) {
+	dp := jsonDataPoint{
+		Labels:            jsonKeyValues(r.Labels().ToSlice()),
+		StartTimeUnixNano: r.StartTime().UnixNano(),
+		TimeUnixNano:      r.EndTime().UnixNano(),
+	}
+	var err error
+	switch agg := r.Aggregation().(type) {
+	case aggregation.Histogram:
+		var buckets aggregation.Buckets
+		if buckets, err = agg.Histogram(); err != nil {
+			return dp, err
+		}
+		dp.BucketCounts, dp.ExplicitBounds = buckets.Counts, buckets.Boundaries
+		dp.Count, dp.Sum, err = countAndSum(agg, kind)
+	case aggregation.MinMaxSumCount:
+		var min, max number.Number
+		if min, err = agg.Min(); err != nil {
+			return dp, err
+		}
+		if max, err = agg.Max(); err != nil {
+			return dp, err
+		}
+		dp.Min, dp.Max = min.AsInterface(kind), max.AsInterface(kind)
+		dp.Count, dp.Sum, err = countAndSum(agg, kind)
+	case aggregation.Sum:
+		var sum number.Number
+		sum, err = agg.Sum()
+		dp.Sum = sum.AsInterface(kind)
+	case aggregation.LastValue:
+		var value number.Number
+		var t time.Time
+		value, t, err = agg.LastValue()
+		dp.Value, dp.TimeUnixNano = value.AsInterface(kind), t.UnixNano()
+	}
+	return dp, err
+}
+
+func countAndSum(agg interface {
+	aggregation.Count
+	aggregation.Sum
+}, kind number.Kind) (*int64, interface{}, error) {
+	count, err := agg.Count()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum, err := agg.Sum()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &count, sum.AsInterface(kind), nil
+}
+
+func jsonKeyValues(kvs []label.KeyValue) []jsonKeyValue {
+	out := make([]jsonKeyValue, len(kvs))
+	for i, kv := range kvs {
+		out[i] = jsonKeyValue{Key: string(kv.Key), Value: kv.Value.AsInterface()}
+	}
+	return out
+}
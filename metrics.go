@@ -0,0 +1,346 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/metric/processor/reducer"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/unit"
+)
+
+// latencyBoundaries are the explicit bucket boundaries, in milliseconds, of
+// the histogram aggregation. They cover the 0-17000ms range of the
+// simulated requests.
+var latencyBoundaries = []float64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 17000}
+
+// payloadSizeBoundaries are the explicit bucket boundaries, in bytes, of the
+// payload size histogram. They cover the 0-999 byte lines of the simulated
+// requests.
+var payloadSizeBoundaries = []float64{50, 100, 200, 300, 400, 500, 600, 700, 800, 900}
+
+// payloadSizeName is the name of the payload size histogram, whose
+// boundaries differ from those of the latency histograms.
+const payloadSizeName = "appdemo/payload_size_bytes"
+
+// requestLatencyName is the name of the request latency histogram, which is
+// recorded in the configured latency unit.
+const requestLatencyName = "appdemo/request_latency"
+
+// latencyUnits are the units the request latency can be recorded in.
+var latencyUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// aggregatorSelector returns the selector for the named aggregation of
+// value recorders: exact, histogram or exponential. The payload sizes are
+// always a histogram with payloadSizeBoundaries, whatever the aggregation,
+// and the request latency histogram has its boundaries converted from
+// milliseconds to latencyUnit.
+func aggregatorSelector(name string, latencyUnit time.Duration) (export.AggregatorSelector, error) {
+	payloadSize := simple.NewWithHistogramDistribution(payloadSizeBoundaries)
+	var boundaries []float64
+	switch name {
+	case "exact":
+		return instrumentSelector{
+			AggregatorSelector: simple.NewWithExactDistribution(),
+			byName: map[string]export.AggregatorSelector{
+				payloadSizeName: payloadSize,
+			},
+		}, nil
+	case "histogram":
+		boundaries = latencyBoundaries
+	case "exponential":
+		// The SDK has no exponential histogram aggregation yet, so fall
+		// back to explicit boundaries growing by powers of two, which
+		// resolve the short and the long latencies equally well.
+		log.Print("exponential histograms are not supported by the SDK, using exponentially spaced explicit buckets")
+		boundaries = exponentialBoundaries(1, 2, 15)
+	default:
+		return nil, fmt.Errorf("unknown aggregation %q", name)
+	}
+	requestLatency := make([]float64, len(boundaries))
+	for i, b := range boundaries {
+		requestLatency[i] = b * float64(time.Millisecond) / float64(latencyUnit)
+	}
+	return instrumentSelector{
+		AggregatorSelector: simple.NewWithHistogramDistribution(boundaries),
+		byName: map[string]export.AggregatorSelector{
+			payloadSizeName:    payloadSize,
+			requestLatencyName: simple.NewWithHistogramDistribution(requestLatency),
+		},
+	}, nil
+}
+
+// instrumentSelector selects the aggregators of the instruments in byName
+// with their own selector, and those of all other instruments with the
+// embedded one.
+type instrumentSelector struct {
+	export.AggregatorSelector
+	byName map[string]export.AggregatorSelector
+}
+
+func (s instrumentSelector) AggregatorFor(descriptor *metric.Descriptor, aggPtrs ...*export.Aggregator) {
+	if selector, ok := s.byName[descriptor.Name()]; ok {
+		selector.AggregatorFor(descriptor, aggPtrs...)
+		return
+	}
+	s.AggregatorSelector.AggregatorFor(descriptor, aggPtrs...)
+}
+
+// exportKindSelector returns the selector for the named temporality of the
+// exported metrics: cumulative or delta. Some backends only accept delta.
+func exportKindSelector(temporality string) (export.ExportKindSelector, error) {
+	switch temporality {
+	case "cumulative":
+		return export.CumulativeExportKindSelector(), nil
+	case "delta":
+		return export.DeltaExportKindSelector(), nil
+	default:
+		return nil, fmt.Errorf("unknown temporality %q", temporality)
+	}
+}
+
+// exponentialBoundaries returns n bucket boundaries starting at start, each
+// factor times the previous one.
+func exponentialBoundaries(start, factor float64, n int) []float64 {
+	boundaries := make([]float64, n)
+	for i := range boundaries {
+		boundaries[i] = start
+		start *= factor
+	}
+	return boundaries
+}
+
+// labelKeepSelector drops every label whose key is not in the set from all
+// instruments before aggregation. The SDK does not support metric views
+// yet, so this stands in for a view that removes high-cardinality labels.
+type labelKeepSelector map[label.Key]struct{}
+
+var _ reducer.LabelFilterSelector = labelKeepSelector{}
+
+func newLabelKeepSelector(keys []string) labelKeepSelector {
+	s := make(labelKeepSelector, len(keys))
+	for _, k := range keys {
+		s[label.Key(k)] = struct{}{}
+	}
+	return s
+}
+
+func (s labelKeepSelector) LabelFilterFor(*metric.Descriptor) label.Filter {
+	return func(kv label.KeyValue) bool {
+		_, ok := s[kv.Key]
+		return ok
+	}
+}
+
+// int64Adder is a counter with its labels already chosen. Bound counters
+// implement it, as does unboundInt64Counter.
+type int64Adder interface {
+	Add(ctx context.Context, value int64)
+}
+
+// int64Recorder is a value recorder with its labels already chosen. Bound
+// recorders implement it, as does unboundInt64ValueRecorder.
+type int64Recorder interface {
+	Record(ctx context.Context, value int64)
+}
+
+// float64Recorder is a value recorder with its labels already chosen. Bound
+// recorders implement it, as does unboundFloat64ValueRecorder.
+type float64Recorder interface {
+	Record(ctx context.Context, value float64)
+}
+
+// unboundInt64Counter passes its labels on every Add. Unlike a bound
+// counter it holds no reference into the SDK, at the cost of looking the
+// labels up on each measurement.
+type unboundInt64Counter struct {
+	counter metric.Int64Counter
+	labels  []label.KeyValue
+}
+
+func (c unboundInt64Counter) Add(ctx context.Context, value int64) {
+	c.counter.Add(ctx, value, c.labels...)
+}
+
+// unboundInt64ValueRecorder passes its labels on every Record, like
+// unboundInt64Counter.
+type unboundInt64ValueRecorder struct {
+	recorder metric.Int64ValueRecorder
+	labels   []label.KeyValue
+}
+
+func (r unboundInt64ValueRecorder) Record(ctx context.Context, value int64) {
+	r.recorder.Record(ctx, value, r.labels...)
+}
+
+// unboundFloat64ValueRecorder passes its labels on every Record, like
+// unboundInt64Counter.
+type unboundFloat64ValueRecorder struct {
+	recorder metric.Float64ValueRecorder
+	labels   []label.KeyValue
+}
+
+func (r unboundFloat64ValueRecorder) Record(ctx context.Context, value float64) {
+	r.recorder.Record(ctx, value, r.labels...)
+}
+
+// withInt64CounterLabels returns c with labels, bound if bound is set. The
+// returned function releases the bound counter.
+func withInt64CounterLabels(c metric.Int64Counter, bound bool, labels ...label.KeyValue) (int64Adder, func()) {
+	if bound {
+		b := c.Bind(labels...)
+		return b, b.Unbind
+	}
+	return unboundInt64Counter{counter: c, labels: labels}, func() {}
+}
+
+// withInt64ValueRecorderLabels returns r with labels, bound if bound is set.
+// The returned function releases the bound recorder.
+func withInt64ValueRecorderLabels(r metric.Int64ValueRecorder, bound bool, labels ...label.KeyValue) (int64Recorder, func()) {
+	if bound {
+		b := r.Bind(labels...)
+		return b, b.Unbind
+	}
+	return unboundInt64ValueRecorder{recorder: r, labels: labels}, func() {}
+}
+
+// withFloat64ValueRecorderLabels returns r with labels, bound if bound is
+// set. The returned function releases the bound recorder.
+func withFloat64ValueRecorderLabels(r metric.Float64ValueRecorder, bound bool, labels ...label.KeyValue) (float64Recorder, func()) {
+	if bound {
+		b := r.Bind(labels...)
+		return b, b.Unbind
+	}
+	return unboundFloat64ValueRecorder{recorder: r, labels: labels}, func() {}
+}
+
+// instrumentCache creates each instrument of a meter once. Instruments are
+// meant to be created at startup and reused; creating them on the request
+// path is a common performance mistake. Asking for an instrument again
+// returns the existing one and logs a warning.
+type instrumentCache struct {
+	meter metric.MeterMust
+
+	mu          sync.Mutex
+	instruments map[string]interface{}
+}
+
+func newInstrumentCache(meter metric.Meter) *instrumentCache {
+	return &instrumentCache{
+		meter:       metric.Must(meter),
+		instruments: make(map[string]interface{}),
+	}
+}
+
+// NewInt64Counter returns the counter called name, creating it on first use.
+func (c *instrumentCache) NewInt64Counter(name string, opts ...metric.InstrumentOption) metric.Int64Counter {
+	return c.instrument(name, func() interface{} {
+		return c.meter.NewInt64Counter(name, opts...)
+	}).(metric.Int64Counter)
+}
+
+// NewInt64ValueRecorder returns the value recorder called name, creating it
+// on first use.
+func (c *instrumentCache) NewInt64ValueRecorder(name string, opts ...metric.InstrumentOption) metric.Int64ValueRecorder {
+	return c.instrument(name, func() interface{} {
+		return c.meter.NewInt64ValueRecorder(name, opts...)
+	}).(metric.Int64ValueRecorder)
+}
+
+// NewFloat64ValueRecorder returns the value recorder called name, creating
+// it on first use.
+func (c *instrumentCache) NewFloat64ValueRecorder(name string, opts ...metric.InstrumentOption) metric.Float64ValueRecorder {
+	return c.instrument(name, func() interface{} {
+		return c.meter.NewFloat64ValueRecorder(name, opts...)
+	}).(metric.Float64ValueRecorder)
+}
+
+// instrument returns the instrument called name, calling create for it on
+// first use. Asking for an existing name with another kind of instrument
+// panics, like registering the conflicting instrument with the SDK would.
+func (c *instrumentCache) instrument(name string, create func() interface{}) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if inst, ok := c.instruments[name]; ok {
+		log.Printf("instrument %s requested again, reusing it; create instruments once at startup", name)
+		return inst
+	}
+	inst := create()
+	c.instruments[name] = inst
+	return inst
+}
+
+// observeUptime registers the appdemo/uptime gauge with meter, reporting the
+// time since start.
+func observeUptime(meter metric.Meter, start time.Time) {
+	metric.Must(meter).NewFloat64ValueObserver(
+		"appdemo/uptime",
+		func(_ context.Context, result metric.Float64ObserverResult) {
+			result.Observe(time.Since(start).Seconds())
+		},
+		metric.WithDescription("The time since the process started"),
+		metric.WithUnit("s"),
+	)
+}
+
+// spansProduced counts the spans started by simulation.work, sampled or
+// not. It is only accessed atomically.
+var spansProduced int64
+
+// observeSpansProduced registers the appdemo/spans_produced_total counter
+// with meter, reporting spansProduced.
+func observeSpansProduced(meter metric.Meter) {
+	metric.Must(meter).NewInt64SumObserver(
+		"appdemo/spans_produced_total",
+		func(_ context.Context, result metric.Int64ObserverResult) {
+			result.Observe(atomic.LoadInt64(&spansProduced))
+		},
+		metric.WithDescription("The number of request spans started, sampled or not"),
+		metric.WithUnit(unit.Dimensionless),
+	)
+}
+
+// observeSamplingRatio registers the appdemo/sampling_ratio gauge with meter,
+// reporting the fraction of new traces sampled by ratio. Ratios outside [0, 1]
+// are reported as the bound the sampler treats them as. Sampled counts can be
+// scaled by its inverse to estimate the unsampled totals.
+func observeSamplingRatio(meter metric.Meter, ratio float64) {
+	if ratio > 1 {
+		ratio = 1
+	} else if ratio < 0 {
+		ratio = 0
+	}
+	metric.Must(meter).NewFloat64ValueObserver(
+		"appdemo/sampling_ratio",
+		func(_ context.Context, result metric.Float64ObserverResult) {
+			result.Observe(ratio)
+		},
+		metric.WithDescription("The configured fraction of new traces that are sampled"),
+		metric.WithUnit(unit.Dimensionless),
+	)
+}
@@ -0,0 +1,135 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/number"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/controller/push"
+	"go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/unit"
+)
+
+// recordingMetricExporter keeps the units of the instruments exported
+// through it, by instrument name.
+type recordingMetricExporter struct {
+	export.ExportKindSelector
+
+	mu    sync.Mutex
+	units map[string]unit.Unit
+}
+
+func newRecordingMetricExporter() *recordingMetricExporter {
+	return &recordingMetricExporter{
+		ExportKindSelector: export.CumulativeExportKindSelector(),
+		units:              make(map[string]unit.Unit),
+	}
+}
+
+func (e *recordingMetricExporter) Export(_ context.Context, cs export.CheckpointSet) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return cs.ForEach(e, func(r export.Record) error {
+		e.units[r.Descriptor().Name()] = r.Descriptor().Unit()
+		return nil
+	})
+}
+
+func TestAggregatorSelector(t *testing.T) {
+	tests := []struct {
+		aggregation string
+		latencyUnit time.Duration
+		instrument  string
+		want        aggregation.Kind
+		// boundaries are the histogram boundaries, unchecked when nil.
+		boundaries []float64
+	}{
+		{"exact", time.Millisecond, requestLatencyName, aggregation.ExactKind, nil},
+		{"exact", time.Millisecond, payloadSizeName, aggregation.HistogramKind, payloadSizeBoundaries},
+		{"histogram", time.Millisecond, requestLatencyName, aggregation.HistogramKind, latencyBoundaries},
+		{"histogram", time.Millisecond, payloadSizeName, aggregation.HistogramKind, payloadSizeBoundaries},
+		{"exponential", time.Millisecond, payloadSizeName, aggregation.HistogramKind, payloadSizeBoundaries},
+		// Only the request latency boundaries are converted to the
+		// latency unit, the other value recorders keep milliseconds.
+		{"histogram", time.Second, requestLatencyName, aggregation.HistogramKind,
+			[]float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 17}},
+		{"histogram", time.Second, "appdemo/other_latency", aggregation.HistogramKind, latencyBoundaries},
+		{"histogram", time.Second, payloadSizeName, aggregation.HistogramKind, payloadSizeBoundaries},
+		{"histogram", time.Nanosecond, requestLatencyName, aggregation.HistogramKind,
+			[]float64{1e7, 5e7, 1e8, 2.5e8, 5e8, 1e9, 2.5e9, 5e9, 1e10, 1.7e10}},
+		{"histogram", time.Nanosecond, "appdemo/other_latency", aggregation.HistogramKind, latencyBoundaries},
+		{"exact", time.Second, requestLatencyName, aggregation.ExactKind, nil},
+	}
+	for _, tt := range tests {
+		selector, err := aggregatorSelector(tt.aggregation, tt.latencyUnit)
+		if err != nil {
+			t.Fatalf("aggregatorSelector(%q, %s): %v", tt.aggregation, tt.latencyUnit, err)
+		}
+		desc := metric.NewDescriptor(tt.instrument, metric.ValueRecorderInstrumentKind, number.Int64Kind)
+		var agg export.Aggregator
+		selector.AggregatorFor(&desc, &agg)
+		if got := agg.Aggregation().Kind(); got != tt.want {
+			t.Errorf("%s aggregation of %s in %s is %s, want %s", tt.aggregation, tt.instrument, tt.latencyUnit, got, tt.want)
+			continue
+		}
+		if tt.boundaries == nil {
+			continue
+		}
+		buckets, err := agg.Aggregation().(aggregation.Histogram).Histogram()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(buckets.Boundaries, tt.boundaries) {
+			t.Errorf("%s aggregation of %s in %s has boundaries %v, want %v", tt.aggregation, tt.instrument, tt.latencyUnit, buckets.Boundaries, tt.boundaries)
+		}
+	}
+}
+
+func TestInstrumentUnits(t *testing.T) {
+	cfg := testConfig(t, "--synthetic-timestamps", "--print-sample-rate=0")
+	exp := newRecordingMetricExporter()
+	pusher := push.New(basic.New(simple.NewWithExactDistribution(), exp), exp, push.WithPeriod(time.Hour))
+	pusher.Start()
+	sim := newTestSimulation(t, cfg, newRecordingTracer(&recordingExporter{}), pusher.MeterProvider().Meter("test"))
+	sim.drive(context.Background(), &run{iterations: 1}, 1)
+	// Stopping the pusher exports what was recorded.
+	pusher.Stop()
+
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+	for name, want := range map[string]unit.Unit{
+		requestLatencyName:    unit.Milliseconds,
+		payloadSizeName:       unit.Bytes,
+		"appdemo/lines_total": unit.Dimensionless,
+	} {
+		got, ok := exp.units[name]
+		if !ok {
+			t.Errorf("%s not exported", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("unit of %s is %q, want %q", name, got, want)
+		}
+	}
+}
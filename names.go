@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// spanNameRule replaces every path segment of a span name that pattern
+// matches in full with placeholder.
+type spanNameRule struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}
+
+// defaultSpanNameRules replace numeric IDs and UUIDs, the most common
+// high-cardinality path segments.
+var defaultSpanNameRules = []spanNameRule{
+	{pattern: regexp.MustCompile(`^[0-9]+$`), placeholder: "{id}"},
+	{pattern: regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`), placeholder: "{id}"},
+}
+
+// normalizeSpanName returns name with the path segments matched by rules
+// replaced by their placeholders, such as /users/{id} for /users/123. A
+// span name is meant to identify a class of operations, and one holding
+// IDs creates a distinct name per request. The first matching rule of a
+// segment applies.
+func normalizeSpanName(name string, rules []spanNameRule) string {
+	if !strings.Contains(name, "/") {
+		return name
+	}
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		for _, rule := range rules {
+			if segment != "" && rule.pattern.MatchString(segment) {
+				segments[i] = rule.placeholder
+				break
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// spanNameRules is a flag.Value holding span name rules, each given as
+// pattern=placeholder. The flag may be repeated to add rules, and setting
+// it replaces the default rules.
+type spanNameRules struct {
+	rules []spanNameRule
+	set   bool
+}
+
+func (r *spanNameRules) String() string {
+	rules := make([]string, len(r.rules))
+	for i, rule := range r.rules {
+		rules[i] = rule.pattern.String() + "=" + rule.placeholder
+	}
+	return strings.Join(rules, " ")
+}
+
+func (r *spanNameRules) Set(value string) error {
+	// The pattern may contain '=' itself, the placeholder is unlikely to.
+	i := strings.LastIndex(value, "=")
+	if i < 0 {
+		return fmt.Errorf("want pattern=placeholder")
+	}
+	pattern, err := regexp.Compile("^(?:" + value[:i] + ")$")
+	if err != nil {
+		return err
+	}
+	if !r.set {
+		r.rules, r.set = nil, true
+	}
+	r.rules = append(r.rules, spanNameRule{pattern: pattern, placeholder: value[i+1:]})
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestNormalizeSpanName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ExecuteRequest", "ExecuteRequest"},
+		{"", ""},
+		{"/users/123", "/users/{id}"},
+		{"users/123", "users/{id}"},
+		{"/users/123/orders/456", "/users/{id}/orders/{id}"},
+		{"/users/0", "/users/{id}"},
+		{"/users/550e8400-e29b-41d4-a716-446655440000", "/users/{id}"},
+		{"/users/550E8400-E29B-41D4-A716-446655440000/cart", "/users/{id}/cart"},
+		// Segments only match in full.
+		{"/users/123abc", "/users/123abc"},
+		{"/v2/users", "/v2/users"},
+		{"/users/550e8400-e29b-41d4-a716", "/users/550e8400-e29b-41d4-a716"},
+		// Empty segments are kept as they are.
+		{"/", "/"},
+		{"//users//123/", "//users//{id}/"},
+		{"123", "123"},
+		{"/123", "/{id}"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSpanName(tt.name, defaultSpanNameRules); got != tt.want {
+			t.Errorf("normalizeSpanName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSpanNameRulesSet(t *testing.T) {
+	rules := spanNameRules{rules: defaultSpanNameRules}
+	for _, value := range []string{
+		`[a-z]+-[0-9]+={sku}`,
+		// Only the last '=' separates the placeholder.
+		`k=v[0-9]+={pair}`,
+	} {
+		if err := rules.Set(value); err != nil {
+			t.Fatalf("Set(%q): %v", value, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"/items/abc-42", "/items/{sku}"},
+		{"/filter/k=v7", "/filter/{pair}"},
+		// The custom rules replace the defaults.
+		{"/users/123", "/users/123"},
+		{"/items/abc-42x", "/items/abc-42x"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSpanName(tt.name, rules.rules); got != tt.want {
+			t.Errorf("normalizeSpanName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got, want := rules.String(), `^(?:[a-z]+-[0-9]+)$={sku} ^(?:k=v[0-9]+)$={pair}`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSpanNameRulesSetInvalid(t *testing.T) {
+	for _, value := range []string{"{id}", "[0-9=={id}", ""} {
+		var rules spanNameRules
+		if err := rules.Set(value); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", value)
+		}
+	}
+}
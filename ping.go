@@ -0,0 +1,116 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	apitrace "go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/unit"
+	"google.golang.org/grpc/connectivity"
+)
+
+// collectorPing periodically exports a single span in a trace of its own
+// and reports whether it got through as the appdemo/collector_up gauge.
+// The span is handed to the exporter directly rather than to the batcher,
+// so that the outcome of every ping is known.
+type collectorPing struct {
+	exporter trace.SpanExporter
+	conn     *connStateTracker
+	res      *resource.Resource
+	// up is 1 after a successful ping and 0 otherwise, accessed atomically.
+	up int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startCollectorPing pings through exporter every interval until the
+// returned function is called.
+func startCollectorPing(exporter trace.SpanExporter, conn *connStateTracker, res *resource.Resource, meter metric.Meter, interval time.Duration) func() {
+	p := &collectorPing{
+		exporter: exporter,
+		conn:     conn,
+		res:      res,
+		done:     make(chan struct{}),
+	}
+	metric.Must(meter).NewInt64ValueObserver(
+		"appdemo/collector_up",
+		func(_ context.Context, result metric.Int64ObserverResult) {
+			result.Observe(atomic.LoadInt64(&p.up))
+		},
+		metric.WithDescription("Whether the last ping of the collector succeeded (1) or not (0)"),
+		metric.WithUnit(unit.Dimensionless),
+	)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.ping()
+			select {
+			case <-ticker.C:
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(p.done)
+		p.wg.Wait()
+	}
+}
+
+func (p *collectorPing) ping() {
+	// The OTLP exporter does not report an error for spans it drops while
+	// disconnected, so the ping also requires a ready connection.
+	err := p.exporter.ExportSpans(context.Background(), []*trace.SpanData{p.span()})
+	state, _ := p.conn.State()
+	var up int64
+	if err == nil && state == connectivity.Ready {
+		up = 1
+	}
+	atomic.StoreInt64(&p.up, up)
+}
+
+// span returns a no-op span starting a new trace.
+func (p *collectorPing) span() *trace.SpanData {
+	var sc apitrace.SpanContext
+	_, _ = rand.Read(sc.TraceID[:])
+	_, _ = rand.Read(sc.SpanID[:])
+	sc.TraceFlags = apitrace.FlagsSampled
+	now := time.Now()
+	return &trace.SpanData{
+		SpanContext: sc,
+		SpanKind:    apitrace.SpanKindInternal,
+		Name:        "CollectorPing",
+		StartTime:   now,
+		EndTime:     now,
+		Resource:    p.res,
+		InstrumentationLibrary: instrumentation.Library{
+			Name: "appdemo/ping",
+		},
+	}
+}
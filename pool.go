@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+)
+
+// workItem is a unit of work handed from f1 to the worker pool. It carries
+// the context of the submitting request so the span the worker starts is a
+// child of the request's span, even though it runs on another goroutine.
+type workItem struct {
+	ctx context.Context
+}
+
+// workerPool runs a work function for the submitted work items on a fixed
+// number of goroutines.
+type workerPool struct {
+	items chan workItem
+	wg    sync.WaitGroup
+}
+
+// newWorkerPool starts size workers. Each worker owns its random number
+// generator.
+func newWorkerPool(size int, work func(context.Context, *rand.Rand)) *workerPool {
+	p := &workerPool{items: make(chan workItem)}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		rng := newRand()
+		go func() {
+			defer p.wg.Done()
+			id := newGoroutineID()
+			for item := range p.items {
+				work(onGoroutine(item.ctx, id), rng)
+			}
+		}()
+	}
+	return p
+}
+
+// submit hands ctx to the next idle worker, blocking while all of them are
+// busy.
+func (p *workerPool) submit(ctx context.Context) {
+	p.items <- workItem{ctx: ctx}
+}
+
+// close stops accepting work and waits for the workers to finish.
+func (p *workerPool) close() {
+	close(p.items)
+	p.wg.Wait()
+}
@@ -0,0 +1,224 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/unit"
+)
+
+// truncatingSpanProcessor shortens string attribute values longer than limit
+// bytes. The SDK only caps the number of attributes per span, so the value
+// length limit is enforced here, before the span reaches the exporter. It
+// must be registered ahead of the batch span processor. Values are cut at a
+// rune boundary, since receivers reject strings that are not valid UTF-8.
+type truncatingSpanProcessor struct {
+	limit int
+}
+
+var _ sdktrace.SpanProcessor = truncatingSpanProcessor{}
+
+func (truncatingSpanProcessor) OnStart(context.Context, *export.SpanData) {}
+
+func (p truncatingSpanProcessor) OnEnd(sd *export.SpanData) {
+	for i, kv := range sd.Attributes {
+		if kv.Value.Type() != label.STRING {
+			continue
+		}
+		if v := kv.Value.AsString(); len(v) > p.limit {
+			sd.Attributes[i] = kv.Key.String(truncateString(v, p.limit))
+		}
+	}
+}
+
+// truncateString returns the longest prefix of s of at most n bytes that
+// does not end in the middle of a rune.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func (truncatingSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (truncatingSpanProcessor) ForceFlush() {}
+
+// rootSpanPrinter prints the trace ID of every new trace to stdout when its
+// root span starts, to look the trace up in the backend. Spans continuing a
+// trace, locally or from a remote parent, are not printed.
+type rootSpanPrinter struct{}
+
+var _ sdktrace.SpanProcessor = rootSpanPrinter{}
+
+func (rootSpanPrinter) OnStart(_ context.Context, sd *export.SpanData) {
+	if sd.ParentSpanID.IsValid() {
+		return
+	}
+	fmt.Printf("trace_id=%s\n", sd.SpanContext.TraceID)
+}
+
+func (rootSpanPrinter) OnEnd(*export.SpanData) {}
+
+func (rootSpanPrinter) Shutdown(context.Context) error { return nil }
+
+func (rootSpanPrinter) ForceFlush() {}
+
+// spanDurationProcessor records the duration of every ended span into the
+// appdemo/span_duration histogram, labeled with the span name. Only spans
+// that are recorded reach OnEnd, so unsampled spans are not measured.
+type spanDurationProcessor struct {
+	duration metric.Float64ValueRecorder
+}
+
+var _ sdktrace.SpanProcessor = spanDurationProcessor{}
+
+// spanNameKey labels the span durations with the name of the span.
+const spanNameKey = label.Key("span.name")
+
+func newSpanDurationProcessor(meter metric.Meter) spanDurationProcessor {
+	return spanDurationProcessor{
+		duration: metric.Must(meter).NewFloat64ValueRecorder(
+			"appdemo/span_duration",
+			metric.WithDescription("The duration of ended spans"),
+			metric.WithUnit(unit.Milliseconds),
+		),
+	}
+}
+
+func (spanDurationProcessor) OnStart(context.Context, *export.SpanData) {}
+
+func (p spanDurationProcessor) OnEnd(sd *export.SpanData) {
+	ms := float64(sd.EndTime.Sub(sd.StartTime)) / float64(time.Millisecond)
+	p.duration.Record(context.Background(), ms, spanNameKey.String(sd.Name))
+}
+
+func (spanDurationProcessor) Shutdown(context.Context) error { return nil }
+
+func (spanDurationProcessor) ForceFlush() {}
+
+// flushingSpanProcessor force flushes the wrapped processor after every n
+// sampled spans that end, so spans are exported at a predictable pace
+// rather than on the batch timeout. The flush runs on the goroutine ending
+// the span. The batch span processor reports its export errors to the
+// global error handler itself, since its ForceFlush returns none. It only
+// exports the spans that made it into the current batch, so spans still
+// queued are left for the next flush.
+type flushingSpanProcessor struct {
+	sdktrace.SpanProcessor
+	n int64
+	// ended counts the sampled spans that ended, accessed atomically.
+	ended int64
+}
+
+// flushEvery returns sp flushed after every n sampled spans, or sp itself
+// if n is not positive.
+func flushEvery(sp sdktrace.SpanProcessor, n int) sdktrace.SpanProcessor {
+	if n <= 0 {
+		return sp
+	}
+	return &flushingSpanProcessor{SpanProcessor: sp, n: int64(n)}
+}
+
+func (p *flushingSpanProcessor) OnEnd(sd *export.SpanData) {
+	p.SpanProcessor.OnEnd(sd)
+	if sd.SpanContext.IsSampled() && atomic.AddInt64(&p.ended, 1)%p.n == 0 {
+		p.SpanProcessor.ForceFlush()
+	}
+}
+
+// syntheticTrafficKey is the span attribute telling generated traffic, such
+// as all of the example's, apart from real traffic.
+const syntheticTrafficKey = label.Key("traffic.synthetic")
+
+// attributeSpanProcessor sets attrs on every span that ends, however it was
+// started, which the instrumentation could not guarantee by itself. The
+// attributes are added past the SDK's attribute count limit.
+type attributeSpanProcessor struct {
+	attrs []label.KeyValue
+}
+
+var _ sdktrace.SpanProcessor = attributeSpanProcessor{}
+
+func (attributeSpanProcessor) OnStart(context.Context, *export.SpanData) {}
+
+func (p attributeSpanProcessor) OnEnd(sd *export.SpanData) {
+	sd.Attributes = append(sd.Attributes, p.attrs...)
+}
+
+func (attributeSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (attributeSpanProcessor) ForceFlush() {}
+
+// redactedValue replaces the values of redacted attributes.
+const redactedValue = "***"
+
+// redactingSpanProcessor replaces the values of span and event attributes
+// whose keys contain any of the denylisted words, ignoring case, with
+// redactedValue. Matching words within keys also catches keys such as
+// db.password or access_token. It must be registered ahead of the batch
+// span processor, so spans are redacted before they are exported.
+type redactingSpanProcessor struct {
+	// denylist holds the lowercased words.
+	denylist []string
+}
+
+var _ sdktrace.SpanProcessor = redactingSpanProcessor{}
+
+func newRedactingSpanProcessor(denylist []string) redactingSpanProcessor {
+	p := redactingSpanProcessor{denylist: make([]string, len(denylist))}
+	for i, word := range denylist {
+		p.denylist[i] = strings.ToLower(word)
+	}
+	return p
+}
+
+func (redactingSpanProcessor) OnStart(context.Context, *export.SpanData) {}
+
+func (p redactingSpanProcessor) OnEnd(sd *export.SpanData) {
+	p.redact(sd.Attributes)
+	for _, e := range sd.MessageEvents {
+		p.redact(e.Attributes)
+	}
+}
+
+func (p redactingSpanProcessor) redact(attrs []label.KeyValue) {
+	for i, kv := range attrs {
+		key := strings.ToLower(string(kv.Key))
+		for _, word := range p.denylist {
+			if strings.Contains(key, word) {
+				attrs[i] = kv.Key.String(redactedValue)
+				break
+			}
+		}
+	}
+}
+
+func (redactingSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (redactingSpanProcessor) ForceFlush() {}
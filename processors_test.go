@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/label"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingExporter keeps the spans exported through it in memory.
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*export.SpanData
+}
+
+func (e *recordingExporter) ExportSpans(_ context.Context, sds []*export.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, sds...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(context.Context) error { return nil }
+
+// onlySpan returns the single span exported through e.
+func (e *recordingExporter) onlySpan(t *testing.T) *export.SpanData {
+	t.Helper()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) != 1 {
+		t.Fatalf("%d spans exported, want 1", len(e.spans))
+	}
+	return e.spans[0]
+}
+
+// attributeMap returns attrs keyed by their keys.
+func attributeMap(attrs []label.KeyValue) map[label.Key]label.Value {
+	m := make(map[label.Key]label.Value, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestSpanLimits(t *testing.T) {
+	const countLimit, lengthLimit = 4, 8
+	exp := &recordingExporter{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithConfig(sdktrace.Config{
+		DefaultSampler:       sdktrace.AlwaysSample(),
+		MaxAttributesPerSpan: countLimit,
+	}))
+	tp.RegisterSpanProcessor(truncatingSpanProcessor{limit: lengthLimit})
+	tp.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(exp))
+
+	_, span := tp.Tracer("test").Start(context.Background(), "SpanLimits")
+	for i := 0; i < countLimit; i++ {
+		span.SetAttributes(label.Int(fmt.Sprintf("attr.%d", i), i))
+	}
+	span.SetAttributes(
+		label.String("ascii", "0123456789"),
+		// Cutting after 8 bytes would split the third euro sign.
+		label.String("utf8", "€€€€"),
+	)
+	span.End()
+
+	sd := exp.onlySpan(t)
+	if got := len(sd.Attributes); got != countLimit {
+		t.Errorf("%d attributes exported, want %d", got, countLimit)
+	}
+	if got, want := sd.DroppedAttributeCount, 2; got != want {
+		t.Errorf("DroppedAttributeCount = %d, want %d", got, want)
+	}
+	attrs := attributeMap(sd.Attributes)
+	// The oldest attributes are evicted first.
+	for _, key := range []label.Key{"attr.0", "attr.1"} {
+		if _, ok := attrs[key]; ok {
+			t.Errorf("attribute %s kept, want it evicted", key)
+		}
+	}
+	for key, want := range map[label.Key]string{"ascii": "01234567", "utf8": "€€"} {
+		if got := attrs[key].AsString(); got != want {
+			t.Errorf("attribute %s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"", 4, ""},
+		{"abc", 4, "abc"},
+		{"abcd", 4, "abcd"},
+		{"abcde", 4, "abcd"},
+		{"ééé", 4, "éé"},
+		{"ééé", 3, "é"},
+		{"€", 2, ""},
+		{"a€", 3, "a"},
+		{"abc", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateString(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRedactingSpanProcessor(t *testing.T) {
+	exp := &recordingExporter{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithConfig(sdktrace.Config{
+		DefaultSampler: sdktrace.AlwaysSample(),
+	}))
+	// Registered ahead of the exporting processor, as in newProviders.
+	tp.RegisterSpanProcessor(newRedactingSpanProcessor([]string{"Password", "token"}))
+	tp.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(exp))
+
+	_, span := tp.Tracer("test").Start(context.Background(), "Login")
+	span.SetAttributes(
+		label.String("user.name", "alice"),
+		label.String("db.PASSWORD", "hunter2"),
+		label.Int("Access_Token", 42),
+	)
+	span.AddEvent("refresh", trace.WithAttributes(
+		label.String("refreshToken", "secret"),
+		label.String("outcome", "ok"),
+	))
+	span.End()
+
+	sd := exp.onlySpan(t)
+	attrs := attributeMap(sd.Attributes)
+	for key, want := range map[label.Key]string{
+		"user.name":    "alice",
+		"db.PASSWORD":  redactedValue,
+		"Access_Token": redactedValue,
+	} {
+		if got := attrs[key].Emit(); got != want {
+			t.Errorf("span attribute %s = %q, want %q", key, got, want)
+		}
+	}
+	if len(sd.MessageEvents) != 1 {
+		t.Fatalf("%d events exported, want 1", len(sd.MessageEvents))
+	}
+	events := attributeMap(sd.MessageEvents[0].Attributes)
+	for key, want := range map[label.Key]string{
+		"refreshToken": redactedValue,
+		"outcome":      "ok",
+	} {
+		if got := events[key].Emit(); got != want {
+			t.Errorf("event attribute %s = %q, want %q", key, got, want)
+		}
+	}
+}
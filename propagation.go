@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceparentRegExp is the format of a traceparent header as defined by
+// https://www.w3.org/TR/trace-context/#traceparent-header.
+var traceparentRegExp = regexp.MustCompile(`^[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// extractTraceparent returns the remote span context of the traceparent
+// header value, extracted with the global propagator like that of an
+// incoming request.
+func extractTraceparent(traceparent string) (trace.SpanContext, error) {
+	if !traceparentRegExp.MatchString(traceparent) {
+		return trace.SpanContext{}, fmt.Errorf("invalid traceparent %q, want version-traceid-parentid-flags", traceparent)
+	}
+	carrier := http.Header{}
+	carrier.Set("traceparent", traceparent)
+	sc := trace.RemoteSpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), carrier))
+	if !sc.IsValid() {
+		return trace.SpanContext{}, fmt.Errorf("traceparent %q holds no valid span context", traceparent)
+	}
+	return sc, nil
+}
+
+// verifyPropagation checks that the configured global propagator carries
+// the span context of a new span across an inject/extract round trip. It
+// logs PASS or FAIL and reports whether the check passed.
+func verifyPropagation(ctx context.Context, tracer trace.Tracer) bool {
+	ctx, span := tracer.Start(ctx, "VerifyPropagation")
+	defer span.End()
+
+	propagator := otel.GetTextMapPropagator()
+	carrier := http.Header{}
+	propagator.Inject(ctx, carrier)
+	extracted := trace.RemoteSpanContextFromContext(propagator.Extract(context.Background(), carrier))
+
+	want := span.SpanContext()
+	if extracted.TraceID != want.TraceID || extracted.SpanID != want.SpanID {
+		log.Printf("propagation FAIL: injected trace_id=%s span_id=%s, extracted trace_id=%s span_id=%s (headers %v)",
+			want.TraceID, want.SpanID, extracted.TraceID, extracted.SpanID, carrier)
+		return false
+	}
+	log.Printf("propagation PASS: trace_id=%s span_id=%s (headers %v)", want.TraceID, want.SpanID, carrier)
+	return true
+}
+
+// dumpHeaders starts a span in ctx and prints the headers the global
+// propagator would send with an outbound request made within it, such as
+// traceparent and baggage, one per line.
+func dumpHeaders(ctx context.Context, tracer trace.Tracer) {
+	ctx, span := tracer.Start(ctx, "DumpHeaders")
+	defer span.End()
+
+	carrier := http.Header{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	names := make([]string, 0, len(carrier))
+	for name := range carrier {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %s\n", strings.ToLower(name), carrier.Get(name))
+	}
+}
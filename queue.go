@@ -0,0 +1,91 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/metric"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/unit"
+)
+
+// spanQueueTracker approximates how full the queue of the batch span
+// processor is, which the SDK does not expose. Spans count as queued from
+// the moment they are handed to the processor until they are handed to its
+// exporter. Spans the processor drops because its queue is full are never
+// exported, so they keep counting as queued and the approximation
+// overestimates after drops; by then the warning is long overdue anyway.
+type spanQueueTracker struct {
+	// queued is the number of spans in the queue, accessed atomically.
+	queued   int64
+	capacity int
+	// warnAt is the utilization above which a warning is logged on each
+	// observation, or zero to never warn.
+	warnAt float64
+}
+
+// processor returns bsp counting the spans it queues.
+func (t *spanQueueTracker) processor(bsp sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return queueCountingSpanProcessor{SpanProcessor: bsp, tracker: t}
+}
+
+// exporter returns exp counting the spans leaving the queue.
+func (t *spanQueueTracker) exporter(exp export.SpanExporter) export.SpanExporter {
+	return queueCountingSpanExporter{SpanExporter: exp, tracker: t}
+}
+
+// observe registers the appdemo/span_queue_utilization gauge with meter.
+func (t *spanQueueTracker) observe(meter metric.Meter) {
+	metric.Must(meter).NewFloat64ValueObserver(
+		"appdemo/span_queue_utilization",
+		func(_ context.Context, result metric.Float64ObserverResult) {
+			queued := atomic.LoadInt64(&t.queued)
+			utilization := float64(queued) / float64(t.capacity)
+			if t.warnAt > 0 && utilization > t.warnAt {
+				log.Printf("span queue %.0f%% full (%d of %d spans), spans will be dropped when it is full", utilization*100, queued, t.capacity)
+			}
+			result.Observe(utilization)
+		},
+		metric.WithDescription("The approximate fraction of the span queue in use"),
+		metric.WithUnit(unit.Dimensionless),
+	)
+}
+
+type queueCountingSpanProcessor struct {
+	sdktrace.SpanProcessor
+	tracker *spanQueueTracker
+}
+
+func (p queueCountingSpanProcessor) OnEnd(sd *export.SpanData) {
+	// The batch span processor only queues sampled spans.
+	if sd.SpanContext.IsSampled() {
+		atomic.AddInt64(&p.tracker.queued, 1)
+	}
+	p.SpanProcessor.OnEnd(sd)
+}
+
+type queueCountingSpanExporter struct {
+	export.SpanExporter
+	tracker *spanQueueTracker
+}
+
+func (e queueCountingSpanExporter) ExportSpans(ctx context.Context, sds []*export.SpanData) error {
+	atomic.AddInt64(&e.tracker.queued, -int64(len(sds)))
+	return e.SpanExporter.ExportSpans(ctx, sds)
+}
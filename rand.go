@@ -0,0 +1,31 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// lastSeed is the seed of the last generator returned by newRand.
+var lastSeed = time.Now().UnixNano()
+
+// newRand returns a random number generator for use on a single goroutine,
+// since rand.Rand is not safe for concurrent use. Every generator gets a
+// distinct seed, even when several are created at the same time.
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(atomic.AddInt64(&lastSeed, 1)))
+}
@@ -0,0 +1,166 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	metricexport "go.opentelemetry.io/otel/sdk/export/metric"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// exporterInfo describes an exporter that can be selected with --exporter.
+type exporterInfo struct {
+	name        string
+	description string
+	// collector reports whether the exporter sends to the collector at
+	// --collector-addr, which the settings of the collector connection
+	// apply to.
+	collector bool
+	// new creates the span and metric exporters. Shutting down the span
+	// exporter shuts down the metric exporter as well.
+	new func(cfg config, conn *connStateTracker) (export.SpanExporter, metricexport.Exporter, error)
+}
+
+// exporters are the exporters that can be selected, in the order they are
+// listed by --list-exporters. Adding an exporter only takes a new entry.
+// The pinned OTLP exporter only speaks gRPC; there is no HTTP/protobuf
+// transport to select, nor signal URL paths to configure.
+var exporters = []exporterInfo{
+	{
+		name:        "otlp",
+		description: "OTLP over gRPC to the collector at --collector-addr",
+		collector:   true,
+		new:         newOTLPExporter,
+	},
+	{
+		name:        "stdout",
+		description: "spans and metrics printed to stdout as JSON, without a collector",
+		new:         newStdoutExporter,
+	},
+}
+
+// lookupExporter returns the exporter with the given name.
+func lookupExporter(name string) (exporterInfo, error) {
+	names := make([]string, len(exporters))
+	for i, e := range exporters {
+		if e.name == name {
+			return e, nil
+		}
+		names[i] = e.name
+	}
+	return exporterInfo{}, fmt.Errorf("unknown exporter %q, available: %s", name, strings.Join(names, ", "))
+}
+
+// listExporters writes the name and description of each exporter to w.
+func listExporters(w io.Writer) {
+	for _, e := range exporters {
+		fmt.Fprintf(w, "%-10s %s\n", e.name, e.description)
+	}
+}
+
+// newOTLPExporter creates an OTLP exporter serving both the trace and the
+// metric pipeline over a single ClientConn, dialed with the options of
+// otlpDialOptions and closed by its Shutdown. The exporter does not accept
+// an existing connection, so the dial options are the one place to
+// configure it. Only this connection is tracked by conn, not those of the
+// fan-out exporters. The checkpointer asks the exporter for the temporality
+// of each instrument, so the export kind selector only needs to be set
+// here.
+func newOTLPExporter(cfg config, conn *connStateTracker) (export.SpanExporter, metricexport.Exporter, error) {
+	if cfg.userAgent != "" && !validUserAgent(cfg.userAgent) {
+		return nil, nil, fmt.Errorf("invalid user agent %q", cfg.userAgent)
+	}
+	if cfg.grpcLB != "pick_first" && cfg.grpcLB != "round_robin" {
+		return nil, nil, fmt.Errorf("unknown gRPC load balancing policy %q", cfg.grpcLB)
+	}
+	kindSelector, err := exportKindSelector(cfg.temporality)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create export kind selector: %w", err)
+	}
+	exp, err := otlp.NewExporter(
+		otlp.WithInsecure(),
+		otlp.WithAddress(cfg.collectorAddr),
+		otlp.WithGRPCDialOption(append(otlpDialOptions(cfg), grpc.WithStatsHandler(conn))...),
+		otlp.WithMetricExportKindSelector(kindSelector),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return exp, exp, nil
+}
+
+// newStdoutExporter creates a stdout exporter serving both the trace and
+// the metric pipeline, to look at the telemetry without a collector. It
+// connects to nothing, so conn is left alone, and it picks the temporality
+// of each instrument itself.
+func newStdoutExporter(cfg config, conn *connStateTracker) (export.SpanExporter, metricexport.Exporter, error) {
+	exp, err := stdout.NewExporter(stdout.WithPrettyPrint())
+	if err != nil {
+		return nil, nil, err
+	}
+	return exp, exp, nil
+}
+
+// otlpDialOptions returns the options for dialing an OTLP collector.
+func otlpDialOptions(cfg config) []grpc.DialOption {
+	dialOpts := []grpc.DialOption{
+		grpc.WithBlock(), // useful for testing
+	}
+	if cfg.dialer != nil {
+		dialOpts = append(dialOpts, grpc.WithContextDialer(cfg.dialer))
+	}
+	if cfg.keepaliveTime > 0 {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.keepaliveTime,
+			Timeout:             cfg.keepaliveTimeout,
+			PermitWithoutStream: cfg.keepalivePermitWithoutStream,
+		}))
+	}
+	if cfg.userAgent != "" {
+		// gRPC appends its own user agent, so the collector still sees the
+		// gRPC version.
+		dialOpts = append(dialOpts, grpc.WithUserAgent(cfg.userAgent))
+	}
+	if cfg.grpcLB != "pick_first" {
+		// The policy only spreads the load if the resolver returns several
+		// addresses, as the dns resolver does for a headless service. A
+		// service config sent by the resolver takes precedence.
+		dialOpts = append(dialOpts, grpc.WithDefaultServiceConfig(
+			fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, cfg.grpcLB),
+		))
+	}
+	return dialOpts
+}
+
+// validUserAgent reports whether ua can be sent as a user-agent header
+// value: non-empty and printable ASCII.
+func validUserAgent(ua string) bool {
+	if ua == "" {
+		return false
+	}
+	for i := 0; i < len(ua); i++ {
+		if ua[i] < 0x20 || ua[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
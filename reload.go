@@ -0,0 +1,154 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	controllerTime "go.opentelemetry.io/otel/sdk/metric/controller/time"
+)
+
+// reloadOnSIGHUP re-reads the config file at path whenever the process
+// receives SIGHUP and applies the settings that can be changed at runtime.
+// Only push-period is hot-reloadable; all other settings are read once at
+// startup and changing them requires a restart. The returned function stops
+// watching for the signal. Nothing is watched if path is empty.
+func reloadOnSIGHUP(path string, pushPeriod time.Duration, clock *adjustableClock) func() {
+	if path == "" {
+		return func() {}
+	}
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sighup:
+			case <-done:
+				return
+			}
+			settings, err := readConfigFile(path)
+			if err != nil {
+				log.Printf("reload: %v", err)
+				continue
+			}
+			for _, s := range settings {
+				if s.name != "push-period" {
+					continue
+				}
+				d, err := time.ParseDuration(s.value)
+				if err != nil || d <= 0 {
+					log.Printf("reload: invalid push-period %q", s.value)
+					continue
+				}
+				if d != pushPeriod {
+					log.Printf("reload: push period changed from %s to %s", pushPeriod, d)
+					pushPeriod = d
+					clock.setPeriod(d)
+				}
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sighup)
+		close(done)
+	}
+}
+
+// adjustableClock is a push controller clock whose tickers can change their
+// period while running. A stopped controller cannot be started again, and
+// a new one would not collect the instruments created from the old one, so
+// the push period is changed through the clock instead.
+type adjustableClock struct {
+	controllerTime.RealClock
+
+	mu      sync.Mutex
+	tickers []*adjustableTicker
+}
+
+func (c *adjustableClock) Ticker(period time.Duration) controllerTime.Ticker {
+	t := newAdjustableTicker(period)
+	c.mu.Lock()
+	c.tickers = append(c.tickers, t)
+	c.mu.Unlock()
+	return t
+}
+
+// setPeriod changes the period of every ticker created by c.
+func (c *adjustableClock) setPeriod(period time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.tickers {
+		t.setPeriod(period)
+	}
+}
+
+// adjustableTicker is a ticker whose period can be changed. Like
+// time.Ticker, it drops ticks for slow receivers.
+type adjustableTicker struct {
+	c      chan time.Time
+	period chan time.Duration
+	stop   chan struct{}
+}
+
+func newAdjustableTicker(period time.Duration) *adjustableTicker {
+	t := &adjustableTicker{
+		c:      make(chan time.Time, 1),
+		period: make(chan time.Duration),
+		stop:   make(chan struct{}),
+	}
+	go t.run(period)
+	return t
+}
+
+func (t *adjustableTicker) run(period time.Duration) {
+	ticker := time.NewTicker(period)
+	for {
+		select {
+		case now := <-ticker.C:
+			select {
+			case t.c <- now:
+			default:
+			}
+		case period := <-t.period:
+			// The next tick follows a full new period.
+			ticker.Stop()
+			ticker = time.NewTicker(period)
+		case <-t.stop:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+func (t *adjustableTicker) setPeriod(period time.Duration) {
+	select {
+	case t.period <- period:
+	case <-t.stop:
+	}
+}
+
+func (t *adjustableTicker) Stop() {
+	close(t.stop)
+}
+
+func (t *adjustableTicker) C() <-chan time.Time {
+	return t.c
+}
@@ -0,0 +1,216 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// k8sNodeNameKey is not part of the semantic conventions of the pinned
+// version yet.
+const k8sNodeNameKey = label.Key("k8s.node.name")
+
+// processStartTimeKey is the resource attribute holding the time the
+// process started, in RFC 3339 format, to correlate telemetry with
+// restarts. It is not part of the semantic conventions.
+const processStartTimeKey = label.Key("process.start_time")
+
+// Resource attributes locating the deployment, which are not part of the
+// semantic conventions of the pinned version.
+const (
+	deploymentRegionKey = label.Key("deployment.region")
+	hostTimezoneKey     = label.Key("host.timezone")
+)
+
+// vcsRevisionKey is the resource attribute holding the commit SHA the
+// telemetry was produced by, see commitSHA.
+const vcsRevisionKey = label.Key("vcs.revision")
+
+// regionRegExp is the format accepted for deployment regions, such as
+// eu-west-1.
+var regionRegExp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// localeAttributes returns the deployment.region and host.timezone
+// resource attributes. The region is omitted when empty. The timezone
+// defaults to the IANA name of the local time zone, such as Europe/Berlin,
+// which Go only knows when it is set with $TZ; otherwise the abbreviation of
+// the current zone, such as CET, is used. An override must be an IANA time
+// zone name.
+func localeAttributes(region, timezone string) ([]label.KeyValue, error) {
+	var attrs []label.KeyValue
+	if region != "" {
+		if !regionRegExp.MatchString(region) {
+			return nil, fmt.Errorf("invalid deployment region %q", region)
+		}
+		attrs = append(attrs, deploymentRegionKey.String(region))
+	}
+	if timezone == "" {
+		if timezone = time.Local.String(); timezone == "Local" {
+			timezone, _ = time.Now().Zone()
+		}
+	} else if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+	return append(attrs, hostTimezoneKey.String(timezone)), nil
+}
+
+// k8sDetector reads the pod metadata from the environment variables that
+// are usually populated through the Kubernetes downward API:
+//
+//	env:
+//	- name: POD_NAME
+//	  valueFrom:
+//	    fieldRef:
+//	      fieldPath: metadata.name
+//	- name: POD_NAMESPACE
+//	  valueFrom:
+//	    fieldRef:
+//	      fieldPath: metadata.namespace
+//	- name: NODE_NAME
+//	  valueFrom:
+//	    fieldRef:
+//	      fieldPath: spec.nodeName
+//
+// Outside of Kubernetes none of the variables are set and it detects an
+// empty resource.
+type k8sDetector struct{}
+
+var _ resource.Detector = k8sDetector{}
+
+func (k8sDetector) Detect(context.Context) (*resource.Resource, error) {
+	return envResource(map[string]label.Key{
+		"POD_NAME":      semconv.K8SPodNameKey,
+		"POD_NAMESPACE": semconv.K8SNamespaceNameKey,
+		"NODE_NAME":     k8sNodeNameKey,
+	}), nil
+}
+
+// envResource returns a resource with an attribute for each of the
+// environment variables in vars that is set to a non-empty value.
+func envResource(vars map[string]label.Key) *resource.Resource {
+	var attrs []label.KeyValue
+	for env, key := range vars {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, key.String(v))
+		}
+	}
+	if len(attrs) == 0 {
+		return resource.Empty()
+	}
+	return resource.NewWithAttributes(attrs...)
+}
+
+// cloudDetector infers the cloud provider and region from the environment
+// variables set by the managed runtimes of the major providers. It does not
+// query the instance metadata services, so plain virtual machines are
+// usually not detected.
+type cloudDetector struct{}
+
+var _ resource.Detector = cloudDetector{}
+
+func (cloudDetector) Detect(context.Context) (*resource.Resource, error) {
+	var provider label.KeyValue
+	var region string
+	switch {
+	case os.Getenv("AWS_REGION") != "" || os.Getenv("AWS_DEFAULT_REGION") != "":
+		provider = semconv.CloudProviderAWS
+		region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	case os.Getenv("GOOGLE_CLOUD_PROJECT") != "" || os.Getenv("K_SERVICE") != "":
+		provider = semconv.CloudProviderGCP
+		region = firstEnv("GOOGLE_CLOUD_REGION", "FUNCTION_REGION")
+	case os.Getenv("WEBSITE_SITE_NAME") != "":
+		provider = semconv.CloudProviderAzure
+		region = firstEnv("REGION_NAME")
+	default:
+		return resource.Empty(), nil
+	}
+
+	attrs := []label.KeyValue{provider}
+	if region != "" {
+		attrs = append(attrs, semconv.CloudRegionKey.String(region))
+	}
+	return resource.NewWithAttributes(attrs...), nil
+}
+
+// firstEnv returns the value of the first of the environment variables that
+// is set to a non-empty value.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// resourcePriority lists the resource attributes from the most to the least
+// important. Attributes that are not listed rank below all listed ones.
+var resourcePriority = []label.Key{
+	semconv.ServiceNameKey,
+	semconv.ServiceVersionKey,
+	vcsRevisionKey,
+	semconv.CloudProviderKey,
+	semconv.CloudRegionKey,
+	semconv.K8SNamespaceNameKey,
+	semconv.K8SPodNameKey,
+	k8sNodeNameKey,
+}
+
+// limitResource warns when res has more than limit attributes. If trim is
+// set, it also drops the lowest-priority attributes past the limit, rather
+// than leaving it to the collector to reject the resource. A limit of zero
+// disables the check.
+func limitResource(res *resource.Resource, limit int, trim bool) *resource.Resource {
+	if limit <= 0 || res.Len() <= limit {
+		return res
+	}
+	if !trim {
+		log.Printf("resource has %d attributes, more than the limit of %d", res.Len(), limit)
+		return res
+	}
+
+	rank := make(map[label.Key]int, len(resourcePriority))
+	for i, key := range resourcePriority {
+		rank[key] = i
+	}
+	attrs := res.Attributes()
+	// The attributes are sorted by key, so the unlisted ones keep that
+	// order among themselves.
+	sort.SliceStable(attrs, func(i, j int) bool {
+		ri, ok := rank[attrs[i].Key]
+		if !ok {
+			ri = len(resourcePriority)
+		}
+		rj, ok := rank[attrs[j].Key]
+		if !ok {
+			rj = len(resourcePriority)
+		}
+		return ri < rj
+	})
+	for _, kv := range attrs[limit:] {
+		log.Printf("resource has %d attributes, dropping %s to stay within the limit of %d", len(attrs), kv.Key, limit)
+	}
+	return resource.NewWithAttributes(attrs[:limit]...)
+}
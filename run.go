@@ -0,0 +1,147 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// run hands out the iterations of a run to the loop drivers, and ends the
+// run for all of them after the configured number of iterations, when ctx
+// is done or when the input runs out.
+type run struct {
+	iterations int
+	duration   time.Duration
+	// input provides a line of input per iteration when not nil.
+	input *bufio.Scanner
+	// tagIterations sets the loop.iteration attribute of every iteration.
+	tagIterations bool
+	// pause holds back new iterations while set, when not nil.
+	pause *pauseSwitch
+	// parent is the remote parent of the root span of the first iteration,
+	// if valid.
+	parent trace.SpanContext
+
+	mu    sync.Mutex
+	n     int
+	ended bool
+}
+
+// next returns the context and attributes of the next iteration, which is
+// a copy of ctx, or false once the run has ended. The reason the run ended
+// is logged once.
+func (r *run) next(ctx context.Context) (context.Context, []label.KeyValue, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return nil, nil, false
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return r.end("run ended after %s and %d iterations", r.duration, r.n)
+	case context.Canceled:
+		return r.end("run canceled after %d iterations", r.n)
+	}
+	if r.iterations > 0 && r.n >= r.iterations {
+		return r.end("run ended after %d iterations", r.n)
+	}
+	var attrs []label.KeyValue
+	if r.input != nil {
+		if !r.input.Scan() {
+			if err := r.input.Err(); err != nil {
+				log.Printf("failed to read stdin: %v", err)
+			}
+			return r.end("run ended with the input after %d iterations", r.n)
+		}
+		attrs = append(attrs, inputLineKey.String(r.input.Text()))
+	}
+	if r.n == 0 && r.parent.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, r.parent)
+	}
+	r.n++
+	if r.tagIterations {
+		attrs = append(attrs, loopIterationKey.Int64(int64(r.n)))
+	}
+	return ctx, attrs, true
+}
+
+func (r *run) end(format string, v ...interface{}) (context.Context, []label.KeyValue, bool) {
+	log.Printf(format, v...)
+	r.ended = true
+	return nil, nil, false
+}
+
+// drive runs the iterations of r on n goroutines, each with a random number
+// generator of its own. It returns once the run has ended and every driver
+// finished its request in progress.
+func (s *simulation) drive(ctx context.Context, r *run, n int) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		rng := newRand()
+		ctx := onGoroutine(ctx, newGoroutineID())
+		go func() {
+			defer wg.Done()
+			defer s.flushOnPanic()
+			for {
+				r.pause.wait(ctx)
+				ctx, attrs, ok := r.next(ctx)
+				if !ok {
+					return
+				}
+				s.f1(ctx, rng, attrs...)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// pauseInterval is how often a paused loop driver checks whether it was
+// resumed.
+const pauseInterval = 100 * time.Millisecond
+
+// pauseSwitch pauses and resumes the loop drivers between requests. A nil
+// pauseSwitch is never paused.
+type pauseSwitch struct {
+	// paused is 1 while paused, accessed atomically.
+	paused int32
+}
+
+func (p *pauseSwitch) pause()  { atomic.StoreInt32(&p.paused, 1) }
+func (p *pauseSwitch) resume() { atomic.StoreInt32(&p.paused, 0) }
+
+func (p *pauseSwitch) isPaused() bool {
+	return p != nil && atomic.LoadInt32(&p.paused) == 1
+}
+
+// wait returns once p is resumed or ctx is done. It returns immediately if
+// p is not paused.
+func (p *pauseSwitch) wait(ctx context.Context) {
+	for p.isPaused() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pauseInterval):
+		}
+	}
+}
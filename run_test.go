@@ -0,0 +1,40 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// TestDriveConcurrently runs requests on several drivers handing their
+// nested work to a worker pool, for go test -race to check.
+func TestDriveConcurrently(t *testing.T) {
+	const iterations = 200
+	cfg := testConfig(t, "--synthetic-timestamps", "--print-sample-rate=0", "--concurrency=4", "--workers=3")
+	exp := &recordingExporter{}
+	sim := newTestSimulation(t, cfg, newRecordingTracer(exp), metric.NoopMeterProvider{}.Meter("test"))
+	sim.drive(context.Background(), &run{iterations: iterations, tagIterations: true}, cfg.concurrency)
+	// The nested work still on the pool has to finish before counting.
+	sim.pool.close()
+
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+	if got, want := len(exp.spans), 2*iterations; got != want {
+		t.Errorf("%d spans exported, want %d", got, want)
+	}
+}
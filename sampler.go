@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/label"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// samplingPriorityKey is the baggage member that, when set to a positive
+// integer, forces the trace to be sampled.
+const samplingPriorityKey = label.Key("sampling.priority")
+
+// prioritySampler samples every span started with a positive
+// sampling.priority attribute and leaves the decision for all other spans
+// to the wrapped sampler.
+//
+// The SDK does not pass the context of the new span to ShouldSample, so the
+// baggage cannot be inspected there directly. Instead, the work functions
+// copy the baggage member into the span start attributes with
+// samplingPriority, which the sampler does get to see.
+type prioritySampler struct {
+	sdktrace.Sampler
+}
+
+var _ sdktrace.Sampler = prioritySampler{}
+
+func (s prioritySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, kv := range p.Attributes {
+		if kv.Key == samplingPriorityKey && kv.Value.AsInt64() > 0 {
+			return sdktrace.SamplingResult{Decision: sdktrace.RecordAndSample}
+		}
+	}
+	return s.Sampler.ShouldSample(p)
+}
+
+func (s prioritySampler) Description() string {
+	return fmt.Sprintf("PrioritySampler{%s}", s.Sampler.Description())
+}
+
+// samplingPriority returns the sampling.priority baggage member of ctx as a
+// span start attribute, or nothing if the member is not set. Propagated
+// baggage carries string values, so the value is normalized to an integer.
+func samplingPriority(ctx context.Context) []label.KeyValue {
+	v := baggage.Value(ctx, samplingPriorityKey)
+	if v.Type() == label.INVALID {
+		return nil
+	}
+	priority, err := strconv.ParseInt(v.Emit(), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return []label.KeyValue{samplingPriorityKey.Int64(priority)}
+}
+
+// maxSamplingLogsPerSecond bounds the sampling decisions loggingSampler logs
+// per second, so logging does not flood the output under load.
+const maxSamplingLogsPerSecond = 10
+
+// loggingSampler logs the decision of the wrapped sampler for every new span
+// with its trace ID and the reason for the decision, at most
+// maxSamplingLogsPerSecond times a second. The number of decisions left out
+// is logged along with the next one that is logged.
+type loggingSampler struct {
+	sdktrace.Sampler
+
+	mu          sync.Mutex
+	windowStart time.Time
+	logged      int
+	suppressed  int
+}
+
+var _ sdktrace.Sampler = (*loggingSampler)(nil)
+
+func (s *loggingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.Sampler.ShouldSample(p)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.Sub(s.windowStart) >= time.Second {
+		s.windowStart, s.logged = now, 0
+	}
+	if s.logged >= maxSamplingLogsPerSecond {
+		s.suppressed++
+		return result
+	}
+	s.logged++
+	decision := "dropped"
+	if result.Decision == sdktrace.RecordAndSample {
+		decision = "sampled"
+	}
+	var suppressed string
+	if s.suppressed > 0 {
+		suppressed = fmt.Sprintf(" (%d decisions not logged)", s.suppressed)
+		s.suppressed = 0
+	}
+	log.Printf("sampling: %s span %q trace_id=%s reason=%q%s", decision, p.Name, p.TraceID, samplingReason(p), suppressed)
+	return result
+}
+
+// samplingReason returns what decides about the span of p in the samplers of
+// the example: its parent, its sampling priority or the ratio.
+func samplingReason(p sdktrace.SamplingParameters) string {
+	if p.ParentContext.IsValid() {
+		parent := "local"
+		if p.HasRemoteParent {
+			parent = "remote"
+		}
+		if p.ParentContext.IsSampled() {
+			return parent + " parent sampled"
+		}
+		return parent + " parent not sampled"
+	}
+	for _, kv := range p.Attributes {
+		if kv.Key == samplingPriorityKey && kv.Value.AsInt64() > 0 {
+			return "sampling priority"
+		}
+	}
+	return "trace ID ratio"
+}
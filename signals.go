@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// cancelOnTermination returns a copy of ctx that is canceled on the first
+// SIGINT or SIGTERM, which ends the run so that the deferred shutdown
+// flushes the telemetry. A further signal, such as one sent because the
+// flush hangs or the container's termination grace period runs out, exits
+// the process right away. The signals stay handled until the process exits.
+func cancelOnTermination(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Printf("received %s, ending the run and flushing; send it again to exit immediately", sig)
+		cancel()
+		sig = <-sigs
+		log.Printf("received %s again, exiting without flushing", sig)
+		os.Exit(1)
+	}()
+	return ctx
+}
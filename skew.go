@@ -0,0 +1,40 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// skewDemo records a trace with timestamps as they could arrive from hosts
+// whose clocks disagree by skew, to see how a backend displays them:
+//
+//	SkewParent      from now to now+2*skew
+//	  EarlyChild    starts skew before its parent
+//	  BackwardsSpan ends skew before it starts
+func skewDemo(ctx context.Context, tracer trace.Tracer, skew time.Duration) {
+	start := time.Now()
+	ctx, parent := tracer.Start(ctx, "SkewParent", trace.WithTimestamp(start))
+	defer parent.End(trace.WithTimestamp(start.Add(2 * skew)))
+
+	_, early := tracer.Start(ctx, "EarlyChild", trace.WithTimestamp(start.Add(-skew)))
+	early.End(trace.WithTimestamp(start.Add(skew)))
+
+	_, backwards := tracer.Start(ctx, "BackwardsSpan", trace.WithTimestamp(start.Add(skew)))
+	backwards.End(trace.WithTimestamp(start))
+}
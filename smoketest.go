@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/label"
+	"google.golang.org/grpc/connectivity"
+)
+
+// initProviderWithin is initProvider, failing once timeout has passed. The
+// exporter dials the collector with grpc.WithBlock and no deadline, so
+// otherwise an unreachable collector would block the smoke test for ever.
+func initProviderWithin(cfg config, conn *connStateTracker, timeout time.Duration) (*providers, error) {
+	type result struct {
+		p   *providers
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		p, err := initProvider(cfg, conn)
+		done <- result{p, err}
+	}()
+	select {
+	case r := <-done:
+		return r.p, r.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no connection to the collector at %s after %s", cfg.collectorAddr, timeout)
+	}
+}
+
+// runSmokeTest records a single span with known attributes and shuts the
+// providers down, which flushes the span to the collector. It returns false
+// if the SDK reported any error while doing so, or if the connection to the
+// collector was not ready: the OTLP exporter drops spans without an error
+// while disconnected.
+func runSmokeTest(cfg config, errs *countingErrorHandler, conn *connStateTracker, shutdown func()) bool {
+	tracer := otel.Tracer(cfg.tracerName)
+	_, span := tracer.Start(context.Background(), "SmokeTest")
+	span.SetAttributes(
+		label.Bool("smoke_test", true),
+		label.String("smoke_test.version", buildVersion()),
+	)
+	span.End()
+
+	// Shutting down closes the connection, so its state is taken first.
+	state, _ := conn.State()
+	shutdown()
+	if state != connectivity.Ready {
+		log.Printf("smoke test failed: collector connection is %s", state)
+		return false
+	}
+	if n := errs.count(); n > 0 {
+		log.Printf("smoke test failed: %d error(s) while exporting", n)
+		return false
+	}
+	log.Print("smoke test passed")
+	return true
+}
@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runStress records a single trace in the given stress pattern, to find the
+// limits of a backend:
+//
+//	deep  sequential sibling spans under a common parent
+//	wide  concurrent sibling spans under a common parent
+//
+// Once ctx is canceled no further children are started.
+func runStress(ctx context.Context, tracer trace.Tracer, cfg config) error {
+	switch cfg.stress {
+	case "deep":
+		deepStress(ctx, tracer, cfg.stressSpans)
+	case "wide":
+		wideStress(ctx, tracer, cfg.stressWidth)
+	default:
+		return fmt.Errorf("unknown stress pattern %q", cfg.stress)
+	}
+	return nil
+}
+
+// deepStress records one trace of n sequential children of a common parent,
+// for backends that limit the number of spans per trace.
+func deepStress(ctx context.Context, tracer trace.Tracer, n int) {
+	ctx, parent := tracer.Start(ctx, "StressDeep", trace.WithAttributes(label.Int("stress.spans", n)))
+	defer parent.End()
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		_, span := tracer.Start(ctx, "StressChild", trace.WithAttributes(label.Int("stress.index", i)))
+		span.End()
+	}
+}
+
+// wideStress records one trace of a parent with n children, each started
+// from its own goroutine sharing the parent's context. The parent ends only
+// once all children have.
+func wideStress(ctx context.Context, tracer trace.Tracer, n int) {
+	ctx, parent := tracer.Start(ctx, "StressWide", trace.WithAttributes(label.Int("stress.width", n)))
+	defer parent.End()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := onGoroutine(ctx, newGoroutineID())
+			attrs := append(goroutineID(ctx), label.Int("stress.index", i))
+			_, span := tracer.Start(ctx, "StressChild", trace.WithAttributes(attrs...))
+			span.End()
+		}(i)
+	}
+	wg.Wait()
+}
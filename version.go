@@ -0,0 +1,50 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// gitCommit is the commit SHA the binary was built from, set at build time
+// with -ldflags "-X main.gitCommit=<sha>".
+var gitCommit string
+
+// buildVersion returns the version of the main module as recorded by the Go
+// toolchain at build time. Binaries built from a local checkout report
+// "(devel)".
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "unknown"
+	}
+	return info.Main.Version
+}
+
+// commitSHA returns the commit SHA the telemetry is attributed to: the
+// GIT_COMMIT environment variable, the one set at build time in gitCommit,
+// or the VCS revision stamped into the binary by the Go toolchain, in that
+// order. Deployments usually only know the commit at runtime, so the
+// environment takes precedence. It returns "" if none is known.
+func commitSHA() string {
+	if sha := os.Getenv("GIT_COMMIT"); sha != "" {
+		return sha
+	}
+	if gitCommit != "" {
+		return gitCommit
+	}
+	return vcsRevision()
+}